@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/config"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// optionsFlag collects repeated -option key=value flags
+type optionsFlag common.Options
+
+func (o optionsFlag) String() string {
+	var entries []string
+	for key, value := range o {
+		entries = append(entries, key+"="+value)
+	}
+	return strings.Join(entries, ",")
+}
+
+func (o optionsFlag) Set(entry string) error {
+	split := strings.SplitN(entry, "=", 2)
+	if len(split) != 2 || split[0] == "" {
+		return fmt.Errorf("option %v is not of the form key=value", entry)
+	}
+	o[split[0]] = split[1]
+	return nil
+}
+
+// targetsFlag collects repeated -target name=output flags
+type targetsFlag []target
+
+func (t *targetsFlag) String() string {
+	var entries []string
+	for _, target := range *t {
+		entries = append(entries, target.generator.Name()+"="+target.outputPath)
+	}
+	return strings.Join(entries, ",")
+}
+
+func (t *targetsFlag) Set(entry string) error {
+	split := strings.SplitN(entry, "=", 2)
+	if len(split) != 2 || split[1] == "" {
+		return fmt.Errorf("target %v is not of the form name=output", entry)
+	}
+	generator, ok := common.Lookup(split[0])
+	if !ok {
+		return fmt.Errorf("unknown target %v", split[0])
+	}
+	*t = append(*t, target{generator: generator, outputPath: split[1]})
+	return nil
+}
+
+type target struct {
+	generator  common.Generator
+	outputPath string
+	// options of this target only, they take precedence over the options of all targets
+	options common.Options
+	types   map[string]string
+}
+
+type args struct {
+	// commandLine holds the flags the args were loaded from
+	commandLine commandLine
+	// inputs are the input paths as given, dbmlPaths the files they expand to
+	inputs    []string
+	dbmlPaths []string
+	targets   []target
+	options   common.Options
+	naming    common.Naming
+	werror    bool
+	check     bool
+	dryRun    bool
+	stdout    bool
+}
+
+// targetOptions returns the options of all targets that apply to the target merged with its own options
+func (a args) targetOptions(target target) common.Options {
+	options := common.TargetOptions(target.generator, a.options)
+	for name, value := range target.options {
+		options[name] = value
+	}
+	return options
+}
+
+// configTarget returns the entry of the configuration file that holds the options of a target given on the command
+// line: the entry with the same name and output or else the only entry with the same name. It returns nil if there is
+// no such entry.
+func configTarget(cfg *config.Config, t target) *config.Target {
+	var found []*config.Target
+	for i, entry := range cfg.Targets {
+		if entry.Name != t.generator.Name() {
+			continue
+		}
+		if filepath.Clean(entry.Output) == filepath.Clean(t.outputPath) {
+			return &cfg.Targets[i]
+		}
+		found = append(found, &cfg.Targets[i])
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	return nil
+}
+
+// commandLine holds the parsed command-line flags, the configuration file is loaded by load
+type commandLine struct {
+	targets []target
+	inputs  []string
+	options common.Options
+	// configPath is the path of the configuration file or empty if there is none
+	configPath string
+	werror     bool
+	check      bool
+	dryRun     bool
+	stdout     bool
+}
+
+// errUsage is returned by load if neither the command line nor the configuration file name inputs and targets
+var errUsage = errors.New("no inputs or targets")
+
+func parseArgs(arguments []string) args {
+	var names []string
+	for _, generator := range common.Generators() {
+		names = append(names, "-"+generator.Name())
+	}
+	flag.Usage = func() { // Showing useful information when the user enters the --help option
+		flag.PrintDefaults()
+		fmt.Printf("%v [-option key=value] [-werror] [-check|-dry-run|-stdout] <dbml-file|glob|-...> "+
+			"<path-to-output>\n", strings.Join(names, "|"))
+		fmt.Printf("-target name=output [-target name=output...] [-option [target.]key=value] [-werror] " +
+			"[-check|-dry-run|-stdout] <dbml-file|glob|-...>\n")
+		fmt.Printf("[-config %v] [flags...]    (inputs and targets are taken from the configuration file)\n",
+			config.FileNames[0])
+		fmt.Printf("watch [flags...]    (regenerates the targets whenever an input, template or the configuration " +
+			"file changes)\n")
+		fmt.Printf("list-targets\n")
+	}
+	selected := map[string]*bool{}
+	for _, generator := range common.Generators() {
+		selected[generator.Name()] = flag.Bool(generator.Name(), false,
+			generator.Description()+" in <path-to-output>")
+	}
+	var targets targetsFlag
+	flag.Var(&targets, "target", "Creates the models of a target (name=output), can be repeated")
+	options := optionsFlag{}
+	flag.Var(options, "option", "Sets an option of the targets ([target.]key=value), can be repeated")
+	werror := flag.Bool("werror", false, "Treats warnings as errors")
+	check := flag.Bool("check", false, "Writes no files but fails with a diff if the files on disk are not up to date")
+	dryRun := flag.Bool("dry-run", false, "Writes no files but lists the files that would be created, overwritten "+
+		"or deleted")
+	stdout := flag.Bool("stdout", false, "Prints the generated files instead of writing them")
+	configPath := flag.String("config", "", fmt.Sprintf("Path of the configuration file (default: %v in the "+
+		"working directory if it exists)", config.FileNames[0]))
+	flag.CommandLine.Parse(arguments)
+
+	modes := 0
+	for _, mode := range []bool{*check, *dryRun, *stdout} {
+		if mode {
+			modes++
+		}
+	}
+	if modes > 1 {
+		exitWithError(fmt.Errorf("only one of -check, -dry-run and -stdout can be used"))
+	}
+
+	if *configPath == "" {
+		*configPath = config.Find(".")
+	}
+
+	legacy := false
+	for _, generator := range common.Generators() {
+		if *selected[generator.Name()] {
+			legacy = true
+			targets = append(targets, target{generator: generator, outputPath: flag.Arg(flag.NArg() - 1)})
+		}
+	}
+	inputs := flag.Args()
+	if legacy {
+		if flag.NArg() < 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		inputs = inputs[:len(inputs)-1]
+	}
+
+	a, err := commandLine{
+		targets:    targets,
+		inputs:     inputs,
+		options:    common.Options(options),
+		configPath: *configPath,
+		werror:     *werror,
+		check:      *check,
+		dryRun:     *dryRun,
+		stdout:     *stdout,
+	}.load()
+	if err == errUsage {
+		flag.Usage()
+		os.Exit(1)
+	} else if err != nil {
+		exitWithError(err)
+	}
+	return a
+}
+
+// load reads the configuration file and merges it with the command line. It is called again by watch when the
+// configuration file changes.
+func (c commandLine) load() (args, error) {
+	cfg := &config.Config{}
+	if c.configPath != "" {
+		var err error
+		if cfg, err = config.Load(c.configPath); err != nil {
+			return args{}, err
+		}
+	}
+
+	targets := append([]target(nil), c.targets...)
+	if len(targets) == 0 {
+		for _, t := range cfg.Targets {
+			generator, ok := common.Lookup(t.Name)
+			if !ok {
+				return args{}, fmt.Errorf("%v: unknown target %v", c.configPath, t.Name)
+			}
+			targets = append(targets, target{generator: generator, outputPath: t.Output, options: t.Options})
+		}
+	} else {
+		for i := range targets {
+			if t := configTarget(cfg, targets[i]); t != nil {
+				targets[i].options = t.Options
+			}
+		}
+	}
+	for i := range targets {
+		targets[i].types = cfg.Types[targets[i].generator.Name()]
+	}
+
+	inputs := c.inputs
+	if len(inputs) == 0 {
+		inputs = cfg.Inputs
+	}
+	if len(inputs) == 0 || len(targets) == 0 {
+		return args{}, errUsage
+	}
+	dbmlPaths, err := expandInputs(inputs)
+	if err != nil {
+		return args{}, err
+	}
+
+	allOptions := common.Options{}
+	for name, value := range cfg.Options {
+		allOptions[name] = value
+	}
+	for name, value := range c.options {
+		allOptions[name] = value
+	}
+	var generators []common.Generator
+	for _, target := range targets {
+		generators = append(generators, target.generator)
+	}
+	if err := common.CheckOptions(generators, c.options); err != nil {
+		return args{}, err
+	}
+	if err := common.CheckOptions(generators, cfg.Options); err != nil {
+		return args{}, fmt.Errorf("%v: %w", c.configPath, err)
+	}
+	for _, target := range targets {
+		if err := common.CheckOptions([]common.Generator{target.generator}, target.options); err != nil {
+			return args{}, fmt.Errorf("%v: target %v: %w", c.configPath, target.generator.Name(), err)
+		}
+	}
+	return args{
+		commandLine: c,
+		inputs:      inputs,
+		dbmlPaths:   dbmlPaths,
+		targets:     targets,
+		options:     allOptions,
+		naming:      cfg.Naming,
+		werror:      c.werror || cfg.Werror,
+		check:       c.check,
+		dryRun:      c.dryRun,
+		stdout:      c.stdout,
+	}, nil
+}
@@ -1,10 +1,6 @@
 package common
 
-import (
-	"os"
-	"regexp"
-	"strings"
-)
+import "os"
 
 // types in dbml
 const TDecimal = "decimal"
@@ -23,61 +19,17 @@ const OUpdatedAt = "UpdatedAt"
 // settings in dbml notes
 const SHidden = "hidden"
 const SBackref = "backref"
+const SSoftDelete = "soft_delete"
 
-// Prefixes in dbml notes
-const PrefixCommon = "all:"
-const PrefixDjango = "django:"
-const PrefixEnt = "ent:"
-
-func WriteToFile(data string, outputPath string) {
+func WriteToFile(data string, outputPath string) error {
 	file, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	_, err = file.WriteString(data)
 	if err != nil {
-		panic(err)
-	}
-	if err := file.Close(); err != nil {
-		panic(err)
-	}
-}
-
-//const matchChars = "[a-zA-Z0-9-_;:<>= ./'\"%&!?]"
-const matchChars = "[a-zA-Z0-9-_;:<>= ./'\"%&!?]"
-
-var commonRe = regexp.MustCompile(PrefixCommon + `\x60([^\x60]*)\x60`)
-var djangoRe = regexp.MustCompile(PrefixDjango + `\x60([^\x60]*)\x60`)
-var entRe = regexp.MustCompile(PrefixEnt + `\x60([^\x60]*)\x60`)
-
-type SettingsType string
-
-// State values.
-const (
-	DJangoSettings SettingsType = "DJangoSettings"
-	EntSettings    SettingsType = "EntSettings"
-)
-
-func GetNoteSettings(note string, settingsType SettingsType) []string {
-	var settings []string
-
-	match := commonRe.FindStringSubmatch(note)
-	if len(match) == 2 {
-		for _, entry := range strings.Split(match[1], " ") {
-			settings = append(settings, entry)
-		}
-	}
-	var reg *regexp.Regexp
-	if settingsType == DJangoSettings {
-		reg = djangoRe
-	} else {
-		reg = entRe
-	}
-	match = reg.FindStringSubmatch(note)
-	if len(match) == 2 {
-		for _, entry := range strings.Split(match[1], " ") {
-			settings = append(settings, entry)
-		}
+		file.Close()
+		return err
 	}
-	return settings
+	return file.Close()
 }
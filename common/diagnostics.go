@@ -0,0 +1,168 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Severity of a Diagnostic.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a warning or error found in the dbml schema.
+type Diagnostic struct {
+	Severity Severity
+	// Target is the name of the target that reported the diagnostic, empty for problems of the schema itself.
+	Target string
+	*Error
+}
+
+// Diagnostics collects the warnings and errors of a run so that all of them can be reported at once.
+type Diagnostics struct {
+	list   []Diagnostic
+	target string
+}
+
+// SetTarget sets the target that is assigned to the diagnostics added from now on.
+func (d *Diagnostics) SetTarget(target string) {
+	d.target = target
+}
+
+// Add adds err with the given severity. Errors that are not an *Error are added without table and column.
+func (d *Diagnostics) Add(severity Severity, err error) {
+	schemaErr, ok := err.(*Error)
+	if !ok {
+		schemaErr = &Error{Msg: err.Error()}
+	}
+	d.list = append(d.list, Diagnostic{Severity: severity, Target: d.target, Error: schemaErr})
+}
+
+// Warnf adds a warning for the given table and column.
+func (d *Diagnostics) Warnf(table string, column string, format string, args ...interface{}) {
+	d.Add(SeverityWarning, NewError(table, column, format, args...))
+}
+
+// Errorf adds an error for the given table and column.
+func (d *Diagnostics) Errorf(table string, column string, format string, args ...interface{}) {
+	d.Add(SeverityError, NewError(table, column, format, args...))
+}
+
+// List returns all diagnostics in the order they were added.
+func (d *Diagnostics) List() []Diagnostic {
+	return d.list
+}
+
+func (d *Diagnostics) count(severity Severity) int {
+	count := 0
+	for _, diagnostic := range d.list {
+		if diagnostic.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Warnings returns the number of warnings.
+func (d *Diagnostics) Warnings() int {
+	return d.count(SeverityWarning)
+}
+
+// Errors returns the number of errors.
+func (d *Diagnostics) Errors() int {
+	return d.count(SeverityError)
+}
+
+// Failed reports whether the run has to fail. With werror warnings are treated as errors.
+func (d *Diagnostics) Failed(werror bool) bool {
+	return d.Errors() > 0 || (werror && d.Warnings() > 0)
+}
+
+// Err returns an error if there are errors.
+func (d *Diagnostics) Err() error {
+	switch errors := d.Errors(); errors {
+	case 0:
+		return nil
+	case 1:
+		for _, diagnostic := range d.list {
+			if diagnostic.Severity == SeverityError {
+				return diagnostic.Error
+			}
+		}
+	}
+	return fmt.Errorf("schema has %v errors", d.Errors())
+}
+
+// Locate adds the source positions to all diagnostics.
+func (d *Diagnostics) Locate(sourceMap *SourceMap) {
+	for _, diagnostic := range d.list {
+		sourceMap.Locate(diagnostic.Error)
+	}
+}
+
+func plural(count int, word string) string {
+	if count == 1 {
+		return fmt.Sprintf("%v %v", count, word)
+	}
+	return fmt.Sprintf("%v %vs", count, word)
+}
+
+// Report writes the diagnostics grouped by table followed by the total counts.
+func (d *Diagnostics) Report(w io.Writer) {
+	if len(d.list) == 0 {
+		return
+	}
+	groups := map[string][]Diagnostic{}
+	var names []string
+	for _, diagnostic := range d.list {
+		if _, ok := groups[diagnostic.Table]; !ok {
+			names = append(names, diagnostic.Table)
+		}
+		groups[diagnostic.Table] = append(groups[diagnostic.Table], diagnostic)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		group := groups[name]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Pos.Line < group[j].Pos.Line })
+		errors, warnings := 0, 0
+		for _, diagnostic := range group {
+			if diagnostic.Severity == SeverityError {
+				errors++
+			} else {
+				warnings++
+			}
+		}
+		title := "Schema"
+		if name != "" {
+			title = "Table " + name
+		}
+		fmt.Fprintf(w, "%v (%v, %v)\n", title, plural(errors, "error"), plural(warnings, "warning"))
+		for _, diagnostic := range group {
+			location := ""
+			if diagnostic.Pos.String() != "" {
+				location = diagnostic.Pos.String() + ": "
+			}
+			target := ""
+			if diagnostic.Target != "" {
+				target = "[" + diagnostic.Target + "] "
+			}
+			column := ""
+			if diagnostic.Column != "" {
+				column = "column " + diagnostic.Column + ": "
+			}
+			fmt.Fprintf(w, "  %v%v: %v%v%v\n", location, diagnostic.Severity, target, column, diagnostic.Msg)
+		}
+	}
+	fmt.Fprintf(w, "%v, %v\n", plural(d.Errors(), "error"), plural(d.Warnings(), "warning"))
+}
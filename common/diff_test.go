@@ -0,0 +1,113 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"create", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"delete", "a\nb\n", "", "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"change", "a\nb\nc\n", "a\nx\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"insert", "a\nc\n", "a\nb\nc\n", "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n"},
+		{"single line", "a\n", "b\n", "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+b\n"},
+		{"no newline at end", "a\nb", "a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"context", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "1\n2\n3\n4\nx\n6\n7\n8\n9\n",
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n"},
+		{"two hunks", "1\nx\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n13\n", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n 1\n-x\n+2\n 3\n 4\n 5\n@@ -9,5 +9,5 @@\n 9\n 10\n 11\n-y\n+12\n 13\n"},
+		{"merged hunks", "1\nx\n3\n4\n5\n6\n7\n8\ny\n10\n", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"--- old\n+++ new\n@@ -1,10 +1,10 @@\n 1\n-x\n+2\n 3\n 4\n 5\n 6\n 7\n 8\n-y\n+9\n 10\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := UnifiedDiff("old", "new", test.old, test.new); got != test.want {
+				t.Errorf("UnifiedDiff() =\n%v\nwant\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestUnifiedDiffApplies applies the diffs of random changes to the old text, which has to give the new text
+func TestUnifiedDiffApplies(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	text := func() string {
+		var lines []string
+		for i := random.Intn(30); i > 0; i-- {
+			lines = append(lines, strconv.Itoa(random.Intn(5)))
+		}
+		str := strings.Join(lines, "\n")
+		if str != "" && random.Intn(4) > 0 {
+			str += "\n"
+		}
+		return str
+	}
+	for i := 0; i < 500; i++ {
+		old, new := text(), text()
+		diff := UnifiedDiff("old", "new", old, new)
+		got, err := applyDiff(old, diff)
+		if err != nil {
+			t.Fatalf("diff of %q and %q does not apply: %v\n%v", old, new, err, diff)
+		}
+		if got != new {
+			t.Fatalf("diff of %q and %q gives %q\n%v", old, new, got, diff)
+		}
+	}
+}
+
+// applyDiff applies a unified diff to text
+func applyDiff(text string, diff string) (string, error) {
+	if diff == "" {
+		return text, nil
+	}
+	old := splitLines(text)
+	var result []string
+	next := 0 // index of the next line of old that is not in result
+	lines := splitLines(diff)[2:]
+	for i := 0; i < len(lines); {
+		var oldStart int
+		if _, err := fmt.Sscanf(lines[i], "@@ -%d", &oldStart); err != nil {
+			return "", fmt.Errorf("no hunk header: %q", lines[i])
+		}
+		if !strings.HasSuffix(strings.Fields(lines[i])[1], ",0") {
+			oldStart-- // lines are counted from 1, the range of an empty hunk is the line before it
+		}
+		if oldStart < next || oldStart > len(old) {
+			return "", fmt.Errorf("hunk %q is out of order or out of range", lines[i])
+		}
+		result = append(result, old[next:oldStart]...)
+		next = oldStart
+		for i++; i < len(lines) && !strings.HasPrefix(lines[i], "@@"); i++ {
+			line := lines[i][1:]
+			if i+1 < len(lines) && lines[i+1] == "\\ No newline at end of file\n" {
+				line = strings.TrimSuffix(line, "\n")
+			}
+			switch lines[i][0] {
+			case ' ', '-':
+				if next >= len(old) || old[next] != line {
+					return "", fmt.Errorf("line %v is not %q", next+1, line)
+				}
+				if lines[i][0] == ' ' {
+					result = append(result, line)
+				}
+				next++
+			case '+':
+				result = append(result, line)
+			case '\\':
+			default:
+				return "", fmt.Errorf("unknown line %q", lines[i])
+			}
+		}
+	}
+	return strings.Join(append(result, old[next:]...), ""), nil
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duythinht/dbml-go/core"
+	"github.com/shifty11/dbml-convert/schema"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Position is a location in a dbml source file. Line and Column start at 1, a zero Line means unknown.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	str := p.File
+	if p.IsValid() {
+		if str != "" {
+			str += ":"
+		}
+		str += fmt.Sprintf("%v:%v", p.Line, p.Column)
+	}
+	return str
+}
+
+// Error is a problem in the dbml schema. Table and Column are empty if the problem is not bound to them.
+type Error struct {
+	Pos    Position
+	Table  string
+	Column string
+	Msg    string
+}
+
+// NewError creates an error for the given table and column.
+func NewError(table string, column string, format string, args ...interface{}) *Error {
+	return &Error{Table: table, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if e.Pos.String() != "" {
+		parts = append(parts, e.Pos.String())
+	}
+	if e.Table != "" && e.Column != "" {
+		parts = append(parts, fmt.Sprintf("table %v, column %v", e.Table, e.Column))
+	} else if e.Table != "" {
+		parts = append(parts, fmt.Sprintf("table %v", e.Table))
+	}
+	parts = append(parts, e.Msg)
+	return strings.Join(parts, ": ")
+}
+
+// RelationSymbol returns the dbml symbol of a relationship type.
+func RelationSymbol(relationType core.RelationshipType) string {
+	switch relationType {
+	case core.OneToOne:
+		return "-"
+	case core.OneToMany:
+		return "<"
+	case core.ManyToOne:
+		return ">"
+	case schema.ManyToMany:
+		return "<>"
+	}
+	return "none"
+}
+
+var tableLineRe = regexp.MustCompile(`^(?i:table)\s+"?([^\s"{]+)`)
+var enumLineRe = regexp.MustCompile(`^(?i:enum)\s+"?([^\s"{]+)`)
+var columnLineRe = regexp.MustCompile(`^"?([^\s"{}]+)"?\s`)
+var parseErrorRe = regexp.MustCompile(`\[(\d+):(\d+)\] `)
+
+// SourceMap knows where tables, columns and enums are defined in one or more dbml source files.
+type SourceMap struct {
+	// file is the file of positions that are not bound to a definition, empty if there is more than one file.
+	file    string
+	tables  map[string]Position
+	columns map[string]Position
+	enums   map[string]Position
+}
+
+// NewSourceMap scans the dbml source for the definitions of tables, columns and enums.
+func NewSourceMap(file string, src string) *SourceMap {
+	sourceMap := &SourceMap{
+		file:    file,
+		tables:  map[string]Position{},
+		columns: map[string]Position{},
+		enums:   map[string]Position{},
+	}
+	table := ""
+	depth := 0
+	for i, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		pos := Position{File: file, Line: i + 1, Column: len(line) - len(strings.TrimLeft(line, " \t")) + 1}
+		if depth == 0 {
+			if match := tableLineRe.FindStringSubmatch(trimmed); match != nil {
+				table = match[1]
+				sourceMap.tables[table] = pos
+			} else if match := enumLineRe.FindStringSubmatch(trimmed); match != nil {
+				table = ""
+				sourceMap.enums[match[1]] = pos
+			} else {
+				table = ""
+			}
+		} else if depth == 1 && table != "" {
+			if match := columnLineRe.FindStringSubmatch(trimmed); match != nil {
+				sourceMap.columns[table+"."+match[1]] = pos
+			}
+		}
+		depth += strings.Count(stripQuoted(line), "{") - strings.Count(stripQuoted(line), "}")
+	}
+	return sourceMap
+}
+
+// Add adds the definitions of other. Definitions that are already known keep their position.
+func (s *SourceMap) Add(other *SourceMap) {
+	if s.file != other.file {
+		s.file = ""
+	}
+	for _, m := range []struct{ dst, src map[string]Position }{
+		{s.tables, other.tables}, {s.columns, other.columns}, {s.enums, other.enums},
+	} {
+		for name, pos := range m.src {
+			if _, ok := m.dst[name]; !ok {
+				m.dst[name] = pos
+			}
+		}
+	}
+}
+
+// stripQuoted removes quoted strings so that braces within notes are not counted
+func stripQuoted(line string) string {
+	var b strings.Builder
+	var quote rune
+	for _, r := range line {
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			}
+		} else if r == '\'' || r == '"' || r == '`' {
+			quote = r
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// TablePos returns the position of the table definition.
+func (s *SourceMap) TablePos(table string) Position {
+	if pos, ok := s.tables[table]; ok {
+		return pos
+	}
+	return Position{File: s.file}
+}
+
+// ColumnPos returns the position of the column definition.
+func (s *SourceMap) ColumnPos(table string, column string) Position {
+	if pos, ok := s.columns[table+"."+column]; ok {
+		return pos
+	}
+	return s.TablePos(table)
+}
+
+// EnumPos returns the position of the enum definition.
+func (s *SourceMap) EnumPos(enum string) Position {
+	if pos, ok := s.enums[enum]; ok {
+		return pos
+	}
+	return Position{File: s.file}
+}
+
+// Locate adds the source position to err if it is an *Error without position.
+func (s *SourceMap) Locate(err error) error {
+	var schemaErr *Error
+	if errors.As(err, &schemaErr) && !schemaErr.Pos.IsValid() {
+		if schemaErr.Column != "" {
+			schemaErr.Pos = s.ColumnPos(schemaErr.Table, schemaErr.Column)
+		} else if schemaErr.Table != "" {
+			schemaErr.Pos = s.TablePos(schemaErr.Table)
+		} else {
+			schemaErr.Pos.File = s.file
+		}
+	}
+	return err
+}
+
+// ParseError converts an error of the dbml parser into an *Error with the position of the invalid token.
+func ParseError(file string, err error) *Error {
+	msg := err.Error()
+	pos := Position{File: file}
+	if match := parseErrorRe.FindStringSubmatchIndex(msg); match != nil {
+		pos.Line, _ = strconv.Atoi(msg[match[2]:match[3]])
+		pos.Column, _ = strconv.Atoi(msg[match[4]:match[5]])
+		msg = msg[:match[0]] + msg[match[1]:]
+	}
+	return &Error{Pos: pos, Msg: msg}
+}
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"fmt"
+	"github.com/duythinht/dbml-go/core"
+	"github.com/shifty11/dbml-convert/schema"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// File is a generated file. Path is relative to the output directory of the target.
+type File struct {
+	Path    string
+	Content string
+}
+
+// Option describes a setting accepted by a Generator.
+type Option struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// Options holds the option values passed to a Generator.
+type Options map[string]string
+
+// Get returns the value of the option or def if it is not set.
+func (o Options) Get(name string, def string) string {
+	if value, ok := o[name]; ok {
+		return value
+	}
+	return def
+}
+
+// Context holds everything a Generator needs to create its files.
+type Context struct {
+	Schema     *schema.Schema
+	OutputPath string
+	Options    Options
+	// Types overrides the type map of the target (dbml type -> target type).
+	Types map[string]string
+	// Naming is the naming convention for the names in the database.
+	Naming Naming
+	// Diagnostics collects the warnings and errors found in the schema.
+	Diagnostics *Diagnostics
+	// Templates are the paths of the templates the generator looked for, whether they exist or not.
+	Templates []string
+}
+
+// NewContext creates a context with empty diagnostics.
+func NewContext(s *schema.Schema, outputPath string, options Options) *Context {
+	return &Context{Schema: s, OutputPath: outputPath, Options: options, Diagnostics: &Diagnostics{}}
+}
+
+// ColumnType returns the target type of a column or an empty string if the type is unknown. Types of the context
+// take precedence over the defaults of the target, they can be given for the type as written in dbml ("text") or
+// for the normalized type ("string").
+func (c *Context) ColumnType(defaults map[string]string, column *schema.Column) string {
+	if targetType, ok := c.Types[column.DBType]; ok {
+		return targetType
+	}
+	if targetType, ok := c.Types[column.Type]; ok {
+		return targetType
+	}
+	return defaults[column.Type]
+}
+
+// ReadTemplate reads a template that is added to the generated file. ok is false if there is no template at path.
+func (c *Context) ReadTemplate(path string) (template string, ok bool) {
+	c.Templates = append(c.Templates, path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Generator converts a dbml schema into the files of one target (Django, Gorm, Ent, ...).
+type Generator interface {
+	// Name is the name of the target, e.g. "django".
+	Name() string
+	// Description is a short human readable description of the target.
+	Description() string
+	// Options lists the options accepted by the target.
+	Options() []Option
+	// Generate creates the files of the target without writing them. Problems in the schema are added to
+	// ctx.Diagnostics, the returned error is reserved for failures that are not caused by the schema.
+	Generate(ctx *Context) ([]File, error)
+}
+
+var generators = map[string]Generator{}
+
+// Register makes a generator available by its name. It panics if a generator with the same name is already
+// registered.
+func Register(generator Generator) {
+	name := generator.Name()
+	if _, ok := generators[name]; ok {
+		panic(fmt.Sprintf("generator %v is already registered", name))
+	}
+	generators[name] = generator
+}
+
+// Lookup returns the generator registered under name.
+func Lookup(name string) (Generator, bool) {
+	generator, ok := generators[name]
+	return generator, ok
+}
+
+// Generators returns all registered generators sorted by name.
+func Generators() []Generator {
+	var list []Generator
+	for _, generator := range generators {
+		list = append(list, generator)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// CreateFiles creates the files of the target registered under name and writes them to outputPath with the default
+// options. It fails with the errors of the schema, warnings are ignored. It backs the CreateXFiles functions the
+// targets had before the Generator interface, new code should use a Generator and WriteOutputs.
+func CreateFiles(name string, dbml *core.DBML, outputPath string) error {
+	generator, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown target %v", name)
+	}
+	diag := &Diagnostics{}
+	diag.SetTarget(name)
+	ctx := NewContext(schema.Build(dbml, diag), outputPath, Options{})
+	ctx.Diagnostics = diag
+	files, err := generator.Generate(ctx)
+	if err != nil {
+		return err
+	}
+	if err := diag.Err(); err != nil {
+		return err
+	}
+	return WriteOutputs([]Output{{Target: name, Path: outputPath, Files: files}})
+}
+
+// TargetOptions returns the options for the generator. Option names can be prefixed with the name of a target
+// ("ent.key"), options without prefix are passed to every generator that accepts them.
+func TargetOptions(generator Generator, options Options) Options {
+	targetOptions := Options{}
+	for name, value := range options {
+		if !strings.Contains(name, ".") && acceptsOption(generator, name) {
+			targetOptions[name] = value
+		}
+	}
+	for name, value := range options {
+		if strings.HasPrefix(name, generator.Name()+".") {
+			targetOptions[strings.TrimPrefix(name, generator.Name()+".")] = value
+		}
+	}
+	return targetOptions
+}
+
+func acceptsOption(generator Generator, name string) bool {
+	for _, option := range generator.Options() {
+		if option.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOptions returns an error if options contains an option that is not accepted by any of the generators.
+func CheckOptions(generators []Generator, options Options) error {
+	var unknown []string
+	for name := range options {
+		accepted := false
+		for _, generator := range generators {
+			if acceptsOption(generator, name) || acceptsOption(generator, strings.TrimPrefix(name, generator.Name()+".")) {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown option(s): %v", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CheckGoDecls returns the syntax error of generated Go declarations (code without package clause) or nil. The line
+// numbers of the error are relative to decls.
+func CheckGoDecls(decls string) error {
+	_, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+decls, parser.AllErrors)
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		return fmt.Errorf("line %v: %v", list[0].Pos.Line-1, list[0].Msg)
+	}
+	return err
+}
+
+// ImportName returns the name a package is assumed to have from its import path like goimports does: the last
+// element without a major version ("validator" for github.com/go-playground/validator/v10), a "go-" prefix and a
+// suffix like ".v2" ("yaml" for gopkg.in/yaml.v2).
+func ImportName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil && path.Dir(importPath) != "." {
+			base = path.Base(path.Dir(importPath))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// FormatGo formats a generated Go file like gofmt and fixes its imports: imports of the known packages (package
+// name -> import path) that are not used are removed and missing ones are added. Imports of other packages, e.g.
+// those of a template, are always kept.
+func FormatGo(src string, packages map[string]string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+			return "", fmt.Errorf("line %v: %v", list[0].Pos.Line, list[0].Msg)
+		}
+		return "", err
+	}
+
+	// package names that are used as qualifier and not declared in the file
+	used := map[string]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	known := map[string]string{} // import path -> package name of the known packages
+	for name, importPath := range packages {
+		known[importPath] = name
+	}
+	imports := map[string]string{} // import path -> explicit name
+	imported := map[string]bool{}  // package names that are imported
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		pkgName, isKnown := known[importPath]
+		if !isKnown {
+			pkgName = ImportName(importPath)
+		}
+		if name != "" {
+			pkgName = name
+		}
+		if !isKnown || name == "_" || name == "." || used[pkgName] {
+			imports[importPath] = name
+			imported[pkgName] = true
+		}
+	}
+	for name := range used {
+		if importPath, ok := packages[name]; ok && !imported[name] {
+			if _, ok := imports[importPath]; ok {
+				continue // imported with another name
+			}
+			imports[importPath] = ""
+			if path.Base(importPath) != name {
+				imports[importPath] = name
+			}
+		}
+	}
+
+	// replace the import declarations by a single block
+	var b strings.Builder
+	offset := 0
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			b.WriteString(src[offset:fset.Position(genDecl.Pos()).Offset])
+			offset = fset.Position(genDecl.End()).Offset
+		}
+	}
+	b.WriteString(src[offset:])
+	withoutImports := b.String()
+	packageEnd := fset.Position(file.Name.End()).Offset
+	formatted := withoutImports[:packageEnd] + "\n\n" + ImportBlock(imports) + withoutImports[packageEnd:]
+
+	result, err := format.Source([]byte(formatted))
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// ImportBlock returns the import declaration of the paths (path -> explicit name, empty for none). The standard
+// library is imported first, separated from the other packages by a blank line.
+func ImportBlock(imports map[string]string) string {
+	if len(imports) == 0 {
+		return ""
+	}
+	var std, other []string
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	str := "import (\n"
+	for i, paths := range [][]string{std, other} {
+		if i > 0 && len(std) > 0 && len(other) > 0 {
+			str += "\n"
+		}
+		for _, path := range paths {
+			if name := imports[path]; name != "" {
+				str += name + " "
+			}
+			str += strconv.Quote(path) + "\n"
+		}
+	}
+	return str + ")\n"
+}
@@ -0,0 +1,66 @@
+package common
+
+import "testing"
+
+func TestImportName(t *testing.T) {
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"time", "time"},
+		{"database/sql", "sql"},
+		{"github.com/shopspring/decimal", "decimal"},
+		{"github.com/go-playground/validator/v10", "validator"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"github.com/mattn/go-sqlite3", "sqlite3"},
+		{"github.com/acme/mixins/v2", "mixins"},
+		{"v2", "v2"},
+	}
+	for _, test := range tests {
+		t.Run(test.importPath, func(t *testing.T) {
+			if got := ImportName(test.importPath); got != test.want {
+				t.Errorf("ImportName(%q) = %q, want %q", test.importPath, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatGo(t *testing.T) {
+	packages := map[string]string{"time": "time", "decimal": "github.com/shopspring/decimal", "sql": "database/sql",
+		"mixins": "github.com/acme/mixins/v2"}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "missing imports are added",
+			src:  "package p\nvar t time.Time\nvar d decimal.Decimal\nvar m mixins.Time\n",
+			want: "package p\n\nimport (\n\t\"time\"\n\n\tmixins \"github.com/acme/mixins/v2\"\n" +
+				"\t\"github.com/shopspring/decimal\"\n)\n\nvar t time.Time\nvar d decimal.Decimal\nvar m mixins.Time\n",
+		},
+		{
+			name: "unused imports of known packages are removed",
+			src:  "package p\nimport (\n\"time\"\n\"database/sql\"\n)\nvar t time.Time\n",
+			want: "package p\n\nimport (\n\t\"time\"\n)\n\nvar t time.Time\n",
+		},
+		{
+			name: "imports of other packages are kept",
+			src: "package p\nimport (\n\"github.com/go-playground/validator/v10\"\n\"gopkg.in/yaml.v2\"\n" +
+				"_ \"embed\"\n)\nvar v = validator.New()\n",
+			want: "package p\n\nimport (\n\t_ \"embed\"\n\n\t\"github.com/go-playground/validator/v10\"\n" +
+				"\t\"gopkg.in/yaml.v2\"\n)\n\nvar v = validator.New()\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := FormatGo(test.src, packages)
+			if err != nil {
+				t.Fatalf("FormatGo() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("FormatGo() =\n%v\nwant\n%v", got, test.want)
+			}
+		})
+	}
+}
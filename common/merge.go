@@ -0,0 +1,62 @@
+package common
+
+import (
+	"github.com/duythinht/dbml-go/core"
+	"github.com/shifty11/dbml-convert/schema"
+)
+
+// MergeDocuments merges the documents into one document with the source map of all documents.
+// Tables and enums that are defined in more than one document are reported as errors and only the first definition
+// is kept, duplicates within a document are left to schema.Build.
+func MergeDocuments(documents []Document, diag *Diagnostics) Document {
+	merged := &core.DBML{}
+	sourceMap := &SourceMap{tables: map[string]Position{}, columns: map[string]Position{}, enums: map[string]Position{}}
+	if len(documents) > 0 {
+		sourceMap.file = documents[0].File
+	}
+	tableFiles := map[string]string{}
+	enumFiles := map[string]string{}
+	projectFile := ""
+	var extensions schema.Extensions
+	for _, document := range documents {
+		dbml := document.DBML
+		if dbml.Project.Name != "" {
+			if projectFile == "" {
+				projectFile = document.File
+				merged.Project = dbml.Project
+			} else {
+				diag.Add(SeverityWarning, &Error{
+					Pos: Position{File: document.File},
+					Msg: "project is already defined in " + projectFile + ", this definition is ignored",
+				})
+			}
+		}
+		for _, table := range dbml.Tables {
+			if file, ok := tableFiles[table.Name]; ok && file != document.File {
+				err := NewError(table.Name, "", "table %v is already defined in %v", table.Name, file)
+				err.Pos = document.SourceMap.TablePos(table.Name)
+				diag.Add(SeverityError, err)
+				continue
+			}
+			tableFiles[table.Name] = document.File
+			merged.Tables = append(merged.Tables, table)
+		}
+		for _, enum := range dbml.Enums {
+			if file, ok := enumFiles[enum.Name]; ok && file != document.File {
+				err := NewError("", "", "enum %v is already defined in %v", enum.Name, file)
+				err.Pos = document.SourceMap.EnumPos(enum.Name)
+				diag.Add(SeverityError, err)
+				continue
+			}
+			enumFiles[enum.Name] = document.File
+			merged.Enums = append(merged.Enums, enum)
+		}
+		merged.Refs = append(merged.Refs, dbml.Refs...)
+		merged.TableGroups = append(merged.TableGroups, dbml.TableGroups...)
+		sourceMap.Add(document.SourceMap)
+		extensions.RefActions = append(extensions.RefActions, document.Extensions.RefActions...)
+		extensions.IndexExpressions = append(extensions.IndexExpressions, document.Extensions.IndexExpressions...)
+		extensions.ColumnTypes = append(extensions.ColumnTypes, document.Extensions.ColumnTypes...)
+	}
+	return Document{DBML: merged, SourceMap: sourceMap, Extensions: extensions}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/schema"
+)
+
+// Table naming conventions
+const (
+	NamingDbml        = "dbml"
+	NamingAlias       = "alias"
+	NamingSnake       = "snake"
+	NamingSnakePlural = "snake_plural"
+)
+
+// STable is the table setting that overrides the name of the table in the database for all targets
+// (all:`table=blog_posts`)
+const STable = "table"
+
+// Naming describes how dbml names are converted into the names used in the database.
+type Naming struct {
+	// Table is one of NamingDbml (default), NamingAlias, NamingSnake or NamingSnakePlural.
+	Table string `yaml:"table"`
+}
+
+// Validate returns an error if a naming convention is unknown.
+func (n Naming) Validate() error {
+	switch n.Table {
+	case "", NamingDbml, NamingAlias, NamingSnake, NamingSnakePlural:
+		return nil
+	}
+	return fmt.Errorf("unknown table naming %v (expected %v, %v, %v or %v)", n.Table, NamingDbml, NamingAlias,
+		NamingSnake, NamingSnakePlural)
+}
+
+// TableName returns the name of the table in the database. A table setting (all:`table=...`) takes precedence over
+// the naming convention. By default the table is named like in dbml, NamingAlias uses the alias of the table
+// (Table posts as P) or its dbml name if it has none and NamingSnakePlural the English plural of the snake case name
+// like Gorm and Ent do by default (category becomes categories, users stays users).
+func (n Naming) TableName(table *schema.Table) string {
+	if name, ok := TableOverride(table); ok {
+		return name
+	}
+	switch n.Table {
+	case NamingAlias:
+		if table.Alias != "" {
+			return table.Alias
+		}
+	case NamingSnake:
+		return table.SnakeName()
+	case NamingSnakePlural:
+		return schema.Plural(table.SnakeName())
+	}
+	return table.Name
+}
+
+// TableOverride returns the name the note of the table sets for all targets (all:`table=...`).
+func TableOverride(table *schema.Table) (string, bool) {
+	name, ok := table.Settings.Value(schema.TargetAll, STable)
+	return name, ok && name != ""
+}
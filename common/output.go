@@ -0,0 +1,276 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Output holds the generated files of one target.
+type Output struct {
+	Target string
+	// Path is the output directory of the target.
+	Path  string
+	Files []File
+}
+
+// ChangeKind is what happens to a file on disk when the outputs are written.
+type ChangeKind int
+
+const (
+	ChangeCreate ChangeKind = iota
+	ChangeOverwrite
+	ChangeUnchanged
+	ChangeDelete
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeCreate:
+		return "create"
+	case ChangeOverwrite:
+		return "overwrite"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unchanged"
+}
+
+// Change is a file that is created, overwritten, left unchanged or deleted when the outputs are written.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	// Manifest is true for the manifest of an output (see ManifestName).
+	Manifest bool
+	// Current is the content on disk, Content the generated content (empty for deleted files).
+	Current string
+	Content string
+	// Added and Removed are the number of added and removed lines.
+	Added   int
+	Removed int
+}
+
+// Diff returns the unified diff of the change or an empty string if the file is unchanged.
+func (c Change) Diff() string {
+	oldName, newName := c.Path, c.Path+" (generated)"
+	if c.Kind == ChangeCreate {
+		oldName = "/dev/null"
+	} else if c.Kind == ChangeDelete {
+		newName = "/dev/null"
+	}
+	return UnifiedDiff(oldName, newName, c.Current, c.Content)
+}
+
+// Headers are the first lines of the generated Go and Python files.
+const (
+	GoHeader     = "// Code generated by dbml-convert. DO NOT EDIT."
+	DjangoHeader = "# Auto generated models. Do not edit by hand!"
+)
+
+// manifestHeader is the first line of a manifest
+const manifestHeader = "# Files generated by dbml-convert, they are deleted once they are no longer generated."
+
+// ManifestName returns the name of the manifest of a target. The manifest is written to the output directory and
+// lists the files of the target (relative to the directory), so that files that are no longer generated can be
+// deleted without touching the files of other targets or tools in the same directory.
+func ManifestName(target string) string {
+	return ".dbml-convert." + target + ".manifest"
+}
+
+// manifest returns the content of the manifest of the output
+func (o Output) manifest() string {
+	var paths []string
+	for _, file := range o.Files {
+		paths = append(paths, filepath.ToSlash(file.Path))
+	}
+	sort.Strings(paths)
+	return manifestHeader + "\n" + strings.Join(append(paths, ""), "\n")
+}
+
+// manifestPaths returns the files listed in a manifest, paths that are not inside the output directory are ignored
+func manifestPaths(content string) []string {
+	var paths []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path := filepath.Clean(filepath.FromSlash(line))
+		if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// plan returns the change of a file on disk to content
+func plan(path string, content string) (Change, error) {
+	change := Change{Kind: ChangeCreate, Path: path, Content: content}
+	current, err := ioutil.ReadFile(path)
+	if err == nil {
+		change.Kind = ChangeOverwrite
+		change.Current = string(current)
+		if change.Current == change.Content {
+			change.Kind = ChangeUnchanged
+		}
+	} else if !os.IsNotExist(err) {
+		return Change{}, err
+	}
+	return countLines(change), nil
+}
+
+// PlanOutputs compares the outputs and their manifests (see ManifestName) with the files on disk. Files that are
+// listed in the manifest of an output on disk but are no longer part of the output are deleted.
+func PlanOutputs(outputs []Output) ([]Change, error) {
+	var changes []Change
+	generated := map[string]bool{}
+	for _, output := range outputs {
+		for _, file := range output.Files {
+			path := filepath.Join(output.Path, file.Path)
+			generated[path] = true
+			change, err := plan(path, file.Content)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+		}
+	}
+	for _, output := range outputs {
+		manifestPath := filepath.Join(output.Path, ManifestName(output.Target))
+		change, err := plan(manifestPath, output.manifest())
+		if err != nil {
+			return nil, err
+		}
+		change.Manifest = true
+		for _, path := range manifestPaths(change.Current) {
+			path = filepath.Join(output.Path, path)
+			if generated[path] {
+				continue
+			}
+			generated[path] = true // listed by the manifests of several outputs
+			current, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			changes = append(changes, countLines(Change{Kind: ChangeDelete, Path: path, Current: string(current)}))
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func countLines(change Change) Change {
+	if change.Kind == ChangeUnchanged {
+		return change
+	}
+	for _, e := range diffLines(splitLines(change.Current), splitLines(change.Content)) {
+		if e.op == '+' {
+			change.Added++
+		} else if e.op == '-' {
+			change.Removed++
+		}
+	}
+	return change
+}
+
+// DescribeChanges returns one line per change with the number of added and removed lines.
+func DescribeChanges(changes []Change) string {
+	var b strings.Builder
+	for _, change := range changes {
+		counts := ""
+		if change.Kind != ChangeUnchanged {
+			counts = fmt.Sprintf(" (+%v -%v)", change.Added, change.Removed)
+		}
+		fmt.Fprintf(&b, "%-9v %v%v\n", change.Kind, change.Path, counts)
+	}
+	return b.String()
+}
+
+// CheckOutputs compares the outputs with the files on disk and returns a unified diff for every file that is
+// missing, differs or would be deleted. The result is empty if all files are up to date, the manifests are not
+// compared.
+func CheckOutputs(outputs []Output) (string, error) {
+	changes, err := PlanOutputs(outputs)
+	if err != nil {
+		return "", err
+	}
+	var diff strings.Builder
+	for _, change := range changes {
+		if !change.Manifest {
+			diff.WriteString(change.Diff())
+		}
+	}
+	return diff.String(), nil
+}
+
+// WriteOutputs writes the files of all outputs and their manifests and deletes the generated files that are no
+// longer part of them (see PlanOutputs). Every file is first written next to its destination and only renamed once all files were
+// written, so that a failing write leaves the existing files untouched.
+func WriteOutputs(outputs []Output) error {
+	changes, err := PlanOutputs(outputs)
+	if err != nil {
+		return err
+	}
+	var written []string
+	cleanup := func() {
+		for _, path := range written {
+			os.Remove(path + ".tmp")
+		}
+	}
+	for _, change := range changes {
+		if change.Kind == ChangeDelete {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(change.Path), 0755); err != nil {
+			cleanup()
+			return err
+		}
+		if err := WriteToFile(change.Content, change.Path+".tmp"); err != nil {
+			cleanup()
+			return err
+		}
+		written = append(written, change.Path)
+	}
+	for _, path := range written {
+		if err := os.Rename(path+".tmp", path); err != nil {
+			cleanup()
+			return err
+		}
+	}
+	for _, change := range changes {
+		if change.Kind == ChangeDelete {
+			if err := os.Remove(change.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintOutputs returns the content of all files. If there is more than one file each one is preceded by a
+// separator line with its path.
+func PrintOutputs(outputs []Output) string {
+	count := 0
+	for _, output := range outputs {
+		count += len(output.Files)
+	}
+	var b strings.Builder
+	for _, output := range outputs {
+		for _, file := range output.Files {
+			if count > 1 {
+				if b.Len() > 0 {
+					b.WriteString("\n")
+				}
+				fmt.Fprintf(&b, "==> %v <==\n", filepath.Join(output.Path, file.Path))
+			}
+			b.WriteString(file.Content)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/duythinht/dbml-go/core"
+	"github.com/duythinht/dbml-go/parser"
+	"github.com/duythinht/dbml-go/scanner"
+	"github.com/shifty11/dbml-convert/schema"
+	"regexp"
+	"strings"
+)
+
+// Document is a parsed dbml file.
+type Document struct {
+	File      string
+	DBML      *core.DBML
+	SourceMap *SourceMap
+	// Extensions are the parts of the source that the dbml parser does not support, see schema.Schema.ApplyExtensions.
+	Extensions schema.Extensions
+}
+
+var refBlockRe = regexp.MustCompile(`^(?i:ref)\b[^:]*\{`)
+var refNameRe = regexp.MustCompile(`^(?i:ref)\s+"?(\w+)"?`)
+var shortRefRe = regexp.MustCompile(`^(\s*(?i:ref)\s*(?:"?(\w+)"?\s*)?:\s*)([^\s<>-]+)\s*(<>|<|>|-)\s*([^\s\[]+)\s*(?:\[([^\]]*)\])?\s*$`)
+var relationshipRe = regexp.MustCompile(`^(\s*)([^\s<>-]+)\s*(<>|<|>|-)\s*([^\s\[]+)\s*(?:\[([^\]]*)\])?\s*$`)
+var inlineManyToManyRe = regexp.MustCompile(`ref:\s*<>\s*([^\s,\]]+)`)
+var emptySettingsRe = regexp.MustCompile(`\s*\[\s*\]\s*$`)
+var indexesRe = regexp.MustCompile(`^(?i:indexes)\s*\{`)
+var typeParamsRe = regexp.MustCompile(`^(\s*"?[^\s"{}]+"?\s+[\w.\[\]]+)\(([^()]*,[^()]*)\)`)
+var indexFieldsRe = regexp.MustCompile("^(\\s*)(\\([^\\[]*\\)|`[^`]*`)(.*)$")
+
+// preprocessor removes the syntax that the dbml parser does not support from the source
+type preprocessor struct {
+	file       string
+	manyToMany []core.Ref
+	extensions schema.Extensions
+}
+
+// ParseDocument parses a dbml source. Many-to-many references (<>), the settings of references
+// ([delete: cascade, update: no action]), expression indexes (`lower(email)`) and types with more than one parameter
+// (decimal(10,2)) are not supported by the dbml parser, they are removed from the source before it is parsed and added
+// to the document. The removal keeps the line numbers
+// of the source.
+func ParseDocument(file string, src []byte) (Document, error) {
+	p := &preprocessor{file: file}
+	source, err := p.run(string(src))
+	if err != nil {
+		return Document{}, err
+	}
+	dbml, err := parser.NewParser(scanner.NewScanner(bytes.NewReader([]byte(source)))).Parse()
+	if err != nil {
+		return Document{}, ParseError(file, err)
+	}
+	dbml.Refs = append(dbml.Refs, p.manyToMany...)
+	return Document{
+		File:       file,
+		DBML:       dbml,
+		SourceMap:  NewSourceMap(file, string(src)),
+		Extensions: p.extensions,
+	}, nil
+}
+
+func (p *preprocessor) run(src string) (string, error) {
+	lines := strings.Split(src, "\n")
+	table, refName := "", ""
+	inRefBlock, inIndexes := false, false
+	index := 0 // position of the next index in the indexes block
+	depth := 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		pos := Position{File: p.file, Line: i + 1, Column: 1}
+		var err error
+		if depth == 0 {
+			table, inRefBlock = "", false
+			if match := tableLineRe.FindStringSubmatch(trimmed); match != nil {
+				table = match[1]
+			} else if refBlockRe.MatchString(trimmed) {
+				inRefBlock = true
+				refName = ""
+				if match := refNameRe.FindStringSubmatch(trimmed); match != nil {
+					refName = match[1]
+				}
+			} else if match := shortRefRe.FindStringSubmatch(line); match != nil {
+				lines[i], err = p.relationship(match[1], match[2], match[3], match[4], match[5], match[6], pos)
+			}
+		} else if depth == 1 && inRefBlock {
+			if match := relationshipRe.FindStringSubmatch(line); match != nil {
+				lines[i], err = p.relationship(match[1], refName, match[2], match[3], match[4], match[5], pos)
+			}
+		} else if depth == 2 && inIndexes {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "//") && !strings.HasPrefix(trimmed, "}") {
+				lines[i] = p.index(table, index, line)
+				index++
+			}
+		} else if depth == 1 && table != "" {
+			inIndexes = indexesRe.MatchString(trimmed)
+			index = 0
+			if match := inlineManyToManyRe.FindStringSubmatch(line); match != nil {
+				if column := columnLineRe.FindStringSubmatch(trimmed); column != nil {
+					p.addManyToMany("", table+"."+column[1], match[1])
+				}
+				lines[i] = removeInlineManyToMany(line)
+			}
+			if match := typeParamsRe.FindStringSubmatch(lines[i]); match != nil && !inIndexes {
+				lines[i] = p.columnType(table, match[1], match[2], lines[i])
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		depth += strings.Count(stripQuoted(line), "{") - strings.Count(stripQuoted(line), "}")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// relationship returns the relationship line without the unsupported parts
+func (p *preprocessor) relationship(prefix string, name string, from string, symbol string, to string,
+	settings string, pos Position) (string, error) {
+	if settings != "" {
+		action := schema.RefAction{From: from, To: to}
+		for _, setting := range strings.Split(settings, ",") {
+			split := strings.SplitN(setting, ":", 2)
+			key := strings.ToLower(strings.TrimSpace(split[0]))
+			if len(split) != 2 || (key != "delete" && key != "update") {
+				return "", &Error{Pos: pos, Msg: fmt.Sprintf("unsupported reference setting %v", strings.TrimSpace(setting))}
+			}
+			value := strings.ToLower(strings.Join(strings.Fields(split[1]), " "))
+			if key == "delete" {
+				action.OnDelete = value
+			} else {
+				action.OnUpdate = value
+			}
+		}
+		p.extensions.RefActions = append(p.extensions.RefActions, action)
+	}
+	if symbol == "<>" {
+		p.addManyToMany(name, from, to)
+		return "", nil
+	}
+	return prefix + from + " " + symbol + " " + to, nil
+}
+
+// index returns the index line without expressions
+func (p *preprocessor) index(table string, index int, line string) string {
+	match := indexFieldsRe.FindStringSubmatch(line)
+	if match == nil || !strings.Contains(match[2], "`") {
+		return line
+	}
+	var columns, expressions []string
+	for _, field := range splitIndexFields(strings.TrimSuffix(strings.TrimPrefix(match[2], "("), ")")) {
+		if strings.HasPrefix(field, "`") {
+			expressions = append(expressions, strings.Trim(field, "`"))
+		} else if field != "" {
+			columns = append(columns, field)
+		}
+	}
+	p.extensions.IndexExpressions = append(p.extensions.IndexExpressions,
+		schema.IndexExpression{Table: table, Index: index, Expressions: expressions})
+	return match[1] + "(" + strings.Join(columns, ", ") + ")" + match[3]
+}
+
+// columnType returns the column line without the parameters of its type, the dbml parser accepts just one
+func (p *preprocessor) columnType(table string, column string, params string, line string) string {
+	columnType := schema.ColumnType{Table: table}
+	if match := columnLineRe.FindStringSubmatch(strings.TrimSpace(column)); match != nil {
+		columnType.Column = match[1]
+	}
+	for _, param := range strings.Split(params, ",") {
+		columnType.TypeParams = append(columnType.TypeParams, strings.TrimSpace(param))
+	}
+	p.extensions.ColumnTypes = append(p.extensions.ColumnTypes, columnType)
+	return column + line[len(column)+len(params)+2:]
+}
+
+// splitIndexFields splits the fields of an index at the commas that are not part of an expression
+func splitIndexFields(fields string) []string {
+	var split []string
+	inExpression := false
+	start := 0
+	for i, r := range fields {
+		if r == '`' {
+			inExpression = !inExpression
+		} else if r == ',' && !inExpression {
+			split = append(split, strings.TrimSpace(fields[start:i]))
+			start = i + 1
+		}
+	}
+	return append(split, strings.TrimSpace(fields[start:]))
+}
+
+func (p *preprocessor) addManyToMany(name string, from string, to string) {
+	p.manyToMany = append(p.manyToMany, core.Ref{
+		Name:          name,
+		Relationships: []core.Relationship{{From: from, To: to, Type: schema.ManyToMany}},
+	})
+}
+
+// removeInlineManyToMany removes the setting "ref: <> table.column" from the settings of a column
+func removeInlineManyToMany(line string) string {
+	loc := inlineManyToManyRe.FindStringIndex(line)
+	before, after := strings.TrimRight(line[:loc[0]], " \t"), strings.TrimLeft(line[loc[1]:], " \t")
+	if strings.HasSuffix(before, ",") {
+		before = strings.TrimSuffix(before, ",")
+	} else if strings.HasPrefix(after, ",") {
+		after = strings.TrimLeft(strings.TrimPrefix(after, ","), " \t")
+	}
+	return emptySettingsRe.ReplaceAllString(before+after, "")
+}
@@ -0,0 +1,169 @@
+package common
+
+import (
+	"github.com/duythinht/dbml-go/core"
+	"github.com/shifty11/dbml-convert/schema"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const parseTables = `Table users {
+  id int [pk]
+  email varchar
+}
+Table posts {
+  id int [pk]
+  author_id int
+}
+`
+
+func manyToManyRef(name string, from string, to string) core.Ref {
+	return core.Ref{Name: name, Relationships: []core.Relationship{{From: from, To: to, Type: schema.ManyToMany}}}
+}
+
+func TestPreprocessor(t *testing.T) {
+	tests := []struct {
+		name           string
+		src            string
+		want           string
+		wantManyToMany []core.Ref
+		wantExtensions schema.Extensions
+	}{
+		{
+			name: "supported syntax is kept",
+			src:  "Ref: posts.author_id > users.id\nRef {\n  posts.id - users.id\n}\n",
+			want: "Ref: posts.author_id > users.id\nRef {\n  posts.id - users.id\n}\n",
+		},
+		{
+			name: "short many-to-many reference",
+			src:  "Ref: posts.id <> users.id\nRef post_users: posts.id <> users.id\n",
+			want: "\n\n",
+			wantManyToMany: []core.Ref{manyToManyRef("", "posts.id", "users.id"),
+				manyToManyRef("post_users", "posts.id", "users.id")},
+		},
+		{
+			name:           "many-to-many reference in a block",
+			src:            "Ref \"post_users\" {\n  posts.id <> users.id\n  posts.author_id > users.id\n}\n",
+			want:           "Ref \"post_users\" {\n\n  posts.author_id > users.id\n}\n",
+			wantManyToMany: []core.Ref{manyToManyRef("post_users", "posts.id", "users.id")},
+		},
+		{
+			name: "inline many-to-many reference",
+			src: "Table posts {\n  tag_ids int [not null, ref: <> tags.id]\n" +
+				"  user_ids int [ref: <> users.id, note: 'x']\n  editor_ids int [ref: <> users.id]\n}\n",
+			want: "Table posts {\n  tag_ids int [not null]\n  user_ids int [note: 'x']\n  editor_ids int\n}\n",
+			wantManyToMany: []core.Ref{manyToManyRef("", "posts.tag_ids", "tags.id"),
+				manyToManyRef("", "posts.user_ids", "users.id"), manyToManyRef("", "posts.editor_ids", "users.id")},
+		},
+		{
+			name: "referential actions",
+			src:  "Ref: posts.author_id > users.id [delete: cascade, update: No  Action]\n",
+			want: "Ref: posts.author_id > users.id\n",
+			wantExtensions: schema.Extensions{RefActions: []schema.RefAction{
+				{From: "posts.author_id", To: "users.id", OnDelete: "cascade", OnUpdate: "no action"}}},
+		},
+		{
+			name: "expression indexes",
+			src: "Table users {\n  email varchar\n  indexes {\n    email [unique]\n" +
+				"    (`lower(email)`, id) [name: 'e']\n    `date(created_at, 'utc')`\n  }\n}\n",
+			want: "Table users {\n  email varchar\n  indexes {\n    email [unique]\n" +
+				"    (id) [name: 'e']\n    ()\n  }\n}\n",
+			wantExtensions: schema.Extensions{IndexExpressions: []schema.IndexExpression{
+				{Table: "users", Index: 1, Expressions: []string{"lower(email)"}},
+				{Table: "users", Index: 2, Expressions: []string{"date(created_at, 'utc')"}}}},
+		},
+		{
+			name: "types with several parameters",
+			src: "Table items {\n  price decimal(10, 2) [not null, note: 'net (a, b)']\n  \"total\" numeric(12,4)\n" +
+				"  name varchar(255)\n}\n",
+			want: "Table items {\n  price decimal [not null, note: 'net (a, b)']\n  \"total\" numeric\n" +
+				"  name varchar(255)\n}\n",
+			wantExtensions: schema.Extensions{ColumnTypes: []schema.ColumnType{
+				{Table: "items", Column: "price", TypeParams: []string{"10", "2"}},
+				{Table: "items", Column: "total", TypeParams: []string{"12", "4"}}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &preprocessor{file: "test.dbml"}
+			got, err := p.run(test.src)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("run() =\n%v\nwant\n%v", got, test.want)
+			}
+			if strings.Count(got, "\n") != strings.Count(test.src, "\n") {
+				t.Errorf("run() changed the number of lines")
+			}
+			if !reflect.DeepEqual(p.manyToMany, test.wantManyToMany) {
+				t.Errorf("many-to-many references = %+v, want %+v", p.manyToMany, test.wantManyToMany)
+			}
+			if !reflect.DeepEqual(p.extensions, test.wantExtensions) {
+				t.Errorf("extensions = %+v, want %+v", p.extensions, test.wantExtensions)
+			}
+		})
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	src := parseTables + "Ref: posts.id <> users.id\nRef: posts.author_id > users.id [delete: cascade]\n"
+	document, err := ParseDocument("test.dbml", []byte(src))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	want := []core.Ref{
+		{Relationships: []core.Relationship{{From: "posts.author_id", To: "users.id", Type: core.ManyToOne}}},
+		manyToManyRef("", "posts.id", "users.id"),
+	}
+	if !reflect.DeepEqual(document.DBML.Refs, want) {
+		t.Errorf("refs = %+v, want %+v", document.DBML.Refs, want)
+	}
+	if len(document.Extensions.RefActions) != 1 || document.Extensions.RefActions[0].OnDelete != "cascade" {
+		t.Errorf("ref actions = %+v, want the action cascade", document.Extensions.RefActions)
+	}
+}
+
+// TestParseDocumentErrors checks that the positions of errors are the positions in the source, also after lines
+// were rewritten
+func TestParseDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantLine int
+		wantMsg  string
+	}{
+		{
+			name:     "after rewritten lines",
+			src:      "Ref: posts.id <> users.id\nTable items {\n  price decimal(10,2)\n  name varchar [x]\n}\n",
+			wantLine: 4,
+		},
+		{
+			name:     "after a removed block line",
+			src:      "Ref {\n  posts.id <> users.id\n}\nTable items {\n  id int [pk]\n  name varchar [x]\n}\n",
+			wantLine: 6,
+		},
+		{
+			name:     "unsupported reference setting",
+			src:      parseTables + "Ref: posts.author_id > users.id [color: red]\n",
+			wantLine: 9,
+			wantMsg:  "unsupported reference setting color: red",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseDocument("test.dbml", []byte(test.src))
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("ParseDocument() error = %v, want an *Error", err)
+			}
+			if e.Pos.File != "test.dbml" || e.Pos.Line != test.wantLine {
+				t.Errorf("position = %v, want line %v", e.Pos, test.wantLine)
+			}
+			if test.wantMsg != "" && e.Msg != test.wantMsg {
+				t.Errorf("message = %q, want %q", e.Msg, test.wantMsg)
+			}
+		})
+	}
+}
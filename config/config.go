@@ -0,0 +1,113 @@
+// Package config loads the project configuration file (dbml-convert.yaml).
+//
+// Example:
+//
+//	inputs:
+//	  - schema.dbml
+//	naming:
+//	  table: snake
+//	types:
+//	  django:
+//	    text: models.TextField
+//	options:
+//	  app_label: shop
+//	targets:
+//	  - name: django
+//	    output: ./backend
+//	  - name: ent
+//	    output: ./api/ent/schema
+//	    options:
+//	      key: value
+package config
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileNames are the names of the configuration file that are discovered in the working directory.
+var FileNames = []string{"dbml-convert.yaml", "dbml-convert.yml"}
+
+// Target is a target with its output directory and options.
+type Target struct {
+	Name    string            `yaml:"name"`
+	Output  string            `yaml:"output"`
+	Options map[string]string `yaml:"options"`
+}
+
+// Config is the project configuration.
+type Config struct {
+	// Inputs are the dbml files or glob patterns, they are merged into one schema.
+	Inputs  []string `yaml:"inputs"`
+	Targets []Target `yaml:"targets"`
+	// Options are passed to all targets that accept them.
+	Options map[string]string `yaml:"options"`
+	// Types overrides the type maps of the targets (target -> dbml type -> target type).
+	Types  map[string]map[string]string `yaml:"types"`
+	Naming common.Naming                `yaml:"naming"`
+	Werror bool                         `yaml:"werror"`
+}
+
+// Find returns the path of the configuration file in dir or an empty string if there is none.
+func Find(dir string) string {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Load reads and validates the configuration file. Relative paths are resolved against the directory of the file.
+// The targets must be registered before the file is loaded.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i, input := range config.Inputs {
+		config.Inputs[i] = resolve(dir, input)
+	}
+	for i, target := range config.Targets {
+		generator, ok := common.Lookup(target.Name)
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown target %v", path, target.Name)
+		}
+		if target.Output == "" {
+			return nil, fmt.Errorf("%v: target %v has no output", path, target.Name)
+		}
+		config.Targets[i].Output = resolve(dir, target.Output)
+		if err := common.CheckOptions([]common.Generator{generator}, target.Options); err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+	}
+	if err := common.CheckOptions(common.Generators(), config.Options); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	for name := range config.Types {
+		if _, ok := common.Lookup(name); !ok {
+			return nil, fmt.Errorf("%v: types for unknown target %v", path, name)
+		}
+	}
+	if err := config.Naming.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return config, nil
+}
+
+func resolve(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
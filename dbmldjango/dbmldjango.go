@@ -5,33 +5,34 @@ import (
 	"github.com/duythinht/dbml-go/core"
 	"github.com/gobeam/stringy"
 	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
 	"github.com/stretchr/stew/slice"
-	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
-func getTemplate(path string) string {
-	template, err := ioutil.ReadFile(path)
-	if err != nil {
-		return "# Auto generated models. Do not edit by hand!\n# Instead add the file 'models.py.template' " +
+func getTemplate(path string, ctx *common.Context) string {
+	template, ok := ctx.ReadTemplate(path)
+	if !ok {
+		return common.DjangoHeader + "\n# Instead add the file 'models.py.template' " +
 			"which will be added to the top of 'models.py'\nimport enum\n\nfrom django.db import models\n\n\n"
 	}
-	return string(template)
+	return template
 }
 
-func dbmlToDjangoString(pythonFile PythonFile, djangoPath string) string {
-	str := getTemplate(filepath.Join(djangoPath, pythonFile.FilePath+".template"))
+func dbmlToDjangoString(pythonFile PythonFile, ctx *common.Context) string {
+	str := getTemplate(filepath.Join(ctx.OutputPath, pythonFile.FilePath+".template"), ctx)
 	for _, enum := range pythonFile.Enums {
 		str += dbmlEnumToDjangoString(enum)
 	}
 	for _, table := range pythonFile.Tables {
-		str += dbmlTableToDjangoString(table, pythonFile.Enums)
+		str += dbmlTableToDjangoString(table, ctx)
 	}
 	return str
 }
 
-func dbmlEnumToDjangoString(enum core.Enum) string {
+func dbmlEnumToDjangoString(enum *schema.Enum) string {
 	str := ""
 	str += fmt.Sprintf("class %v(enum.IntEnum):\n", enum.Name)
 	for i, column := range enum.Values {
@@ -52,10 +53,10 @@ type TableSettings struct {
 	Meta         []string
 }
 
-func parseTableSettings(table core.Table) TableSettings {
+func parseTableSettings(table *schema.Table, diag *common.Diagnostics) TableSettings {
 	settings := TableSettings{Hidden: false}
 
-	settingsStr := common.GetNoteSettings(table.Note, common.DJangoSettings)
+	settingsStr := table.Settings.Get(schema.TargetDjango)
 
 	for _, entry := range settingsStr {
 		if entry == common.SHidden {
@@ -70,18 +71,22 @@ func parseTableSettings(table core.Table) TableSettings {
 		} else if strings.HasPrefix(entry, "meta=") {
 			meta := strings.Replace(entry[:len(entry)-1], "meta=[", "", 1)
 			settings.Meta = strings.Split(meta, " ")
+		} else if strings.HasPrefix(entry, common.STable+"=") {
+			continue // used by ctx.Naming.TableName
+		} else if entry != "" {
+			diag.Warnf(table.Name, "", "ignored table setting %v", entry)
 		}
 	}
 	if settings.ModelPath == "" {
-		panic(fmt.Sprintf("Table %v has no model_path", table.Name))
+		diag.Errorf(table.Name, "", "model_path is missing in the table note (django:`model_path=...`)")
 	}
 	return settings
 }
 
-func parseColumnParameters(column core.Column) []string {
+func parseColumnParameters(column *schema.Column) []string {
 	var params []string
 
-	settingsStr := common.GetNoteSettings(column.Settings.Note, common.DJangoSettings)
+	settingsStr := column.Settings.Get(schema.TargetDjango)
 	for _, settings := range settingsStr {
 		for _, entry := range strings.Split(settings, ";") {
 			params = append(params, entry)
@@ -91,64 +96,51 @@ func parseColumnParameters(column core.Column) []string {
 	return params
 }
 
-func getDbmlColumnSettings(column core.Column) []string {
+func getDbmlColumnSettings(column *schema.Column) []string {
 	var settings []string
 
 	key := "unique"
-	if column.Settings.Unique && !slice.Contains(settings, key) {
+	if column.Unique && !slice.Contains(settings, key) {
 		settings = append(settings, "unique=True")
 	}
 	key = "not null"
-	if column.Settings.Null && !slice.Contains(settings, key) {
+	if column.Null && !slice.Contains(settings, key) {
 		settings = append(settings, "null=True")
 	}
 	key = "default"
-	if column.Settings.Default != "" && !slice.Contains(settings, key) {
+	if column.Default != "" && !slice.Contains(settings, key) {
 		settings = append(settings, fmt.Sprintf("default=%v", parseDefault(column)))
 	}
 	return settings
 }
 
-func parseDefault(column core.Column) string {
+func parseDefault(column *schema.Column) string {
 	if column.Type == common.TBool || column.Type == common.TBoolean {
-		return strings.Title(column.Settings.Default)
+		return strings.Title(column.Default)
 	}
-	return fmt.Sprintf("'%v'", column.Settings.Default)
+	return fmt.Sprintf("'%v'", column.Default)
 }
 
-func getRelationType(column core.Column, paramsString string) string {
-	columnType := ""
-	if len(paramsString) > 0 {
-		paramsString = ", " + paramsString
-	}
-	if column.Settings.Ref.Type == core.OneToOne {
-		columnType = "models.OneToOneField"
-	} else if column.Settings.Ref.Type == core.ManyToOne || column.Settings.Ref.Type == core.OneToMany {
-		columnType = "models.ForeignKey"
-	} else {
-		fmt.Printf("%v was not found in type definition\n", column.Type)
-		return ""
+func getRelationType(column *schema.Column) string {
+	if column.Ref.Type == core.OneToOne {
+		return "models.OneToOneField"
 	}
-	return columnType
+	return "models.ForeignKey"
 }
 
-func getEnumType(enums []core.Enum, column core.Column, columnType string, paramsString string) (string, string) {
-	for _, enum := range enums {
-		if column.Type == enum.Name {
-			columnType = "models.CharField"
-			if len(paramsString) > 0 {
-				paramsString = paramsString + ", "
-			}
-			paramsString += fmt.Sprintf("choices=[(tag, tag.value) for tag in %v]", column.Type)
-		}
+func getEnumType(column *schema.Column, paramsString string) (string, string) {
+	if len(paramsString) > 0 {
+		paramsString = paramsString + ", "
 	}
-	return columnType, paramsString
+	paramsString += fmt.Sprintf("choices=[(tag, tag.value) for tag in %v]", column.Enum.Name)
+	return "models.CharField", paramsString
 }
 
-func dbmlTableToDjangoString(djangoTable DjangoTable, enums []core.Enum) string {
+func dbmlTableToDjangoString(djangoTable DjangoTable, ctx *common.Context) string {
+	diag := ctx.Diagnostics
 	str := ""
 	table := djangoTable.Table
-	settings := parseTableSettings(table)
+	settings := djangoTable.Settings
 	if settings.Hidden {
 		return ""
 	}
@@ -158,9 +150,8 @@ func dbmlTableToDjangoString(djangoTable DjangoTable, enums []core.Enum) string
 	}
 	str += fmt.Sprintf("class %v(%v):\n", table.Name, inheritance)
 	for _, column := range table.Columns {
-		columnSettings := common.GetNoteSettings(column.Settings.Note, common.DJangoSettings)
-		if !slice.Contains(columnSettings, common.SHidden) {
-			columnType := types[column.Type]
+		if !column.Settings.Has(schema.TargetDjango, common.SHidden) {
+			columnType := ctx.ColumnType(types, column)
 			columnParams := parseColumnParameters(column)
 			paramsString := ""
 			if len(columnParams) > 0 {
@@ -173,32 +164,37 @@ func dbmlTableToDjangoString(djangoTable DjangoTable, enums []core.Enum) string
 				}
 			}
 			if columnType == "" {
-				if strings.HasPrefix(column.Type, "[]") {
+				if column.List {
 					continue
 				}
-				if column.Settings.Ref.Type != core.None {
-					columnType = getRelationType(column, paramsString)
-					if columnType == "" && paramsString == "" {
-						continue
-					}
+				if column.Ref != nil {
+					columnType = getRelationType(column)
+				} else if column.Enum != nil {
+					columnType, paramsString = getEnumType(column, paramsString)
+				} else if column.IsVirtual() {
+					diag.Warnf(table.Name, column.Name, "column has the type of table %v but no ref, column is skipped",
+						column.Object.Name)
+					continue
 				} else {
-					columnType, paramsString = getEnumType(enums, column, columnType, paramsString)
+					diag.Errorf(table.Name, column.Name, "unknown column type or enum %v", column.Type)
+					continue
 				}
 			}
-			columnName := strings.ToLower(stringy.New(column.Name).SnakeCase("?", "").Get())
-			str += fmt.Sprintf("    %v = %v(%v)\n", columnName, columnType, paramsString)
+			str += fmt.Sprintf("    %v = %v(%v)\n", column.SnakeName(), columnType, paramsString)
 		}
 	}
-	tableName := strings.ToLower(stringy.New(table.Name).SnakeCase("?", "").Get())
+	str += "\n    class Meta:\n"
 	if len(settings.Meta) > 0 {
-		str += "\n    class Meta:\n"
 		for _, entry := range settings.Meta {
 			str += fmt.Sprintf("        %v\n", strings.Replace(entry, "=", " = ", 1))
 		}
-		str += "\n\n"
 	} else {
-		str += fmt.Sprintf("\n    class Meta:\n        db_table = '%vs'\n\n\n", tableName)
+		str += fmt.Sprintf("        db_table = '%v'\n", ctx.Naming.TableName(table))
 	}
+	if appLabel := ctx.Options.Get(OAppLabel, ""); appLabel != "" {
+		str += fmt.Sprintf("        app_label = '%v'\n", appLabel)
+	}
+	str += "\n\n"
 	return str
 }
 
@@ -209,19 +205,19 @@ type DBMLDjango struct {
 type PythonFile struct {
 	FilePath string
 	Tables   []DjangoTable
-	Enums    []core.Enum
+	Enums    []*schema.Enum
 }
 
 type DjangoTable struct {
-	Table    core.Table
+	Table    *schema.Table
 	Settings TableSettings
 }
 
-func addEnums(currentEnums []core.Enum, enums []core.Enum, table core.Table) []core.Enum {
+func addEnums(currentEnums []*schema.Enum, enums []*schema.Enum, table *schema.Table) []*schema.Enum {
 	for _, enum := range enums {
 		hasEnum := false
 		for _, currentEnum := range currentEnums {
-			if currentEnum.Name == enum.Name {
+			if currentEnum == enum {
 				hasEnum = true
 			}
 		}
@@ -229,28 +225,29 @@ func addEnums(currentEnums []core.Enum, enums []core.Enum, table core.Table) []c
 			continue
 		}
 		for _, column := range table.Columns {
-			if enum.Name == column.Type {
+			if column.Enum == enum {
 				currentEnums = append(currentEnums, enum)
+				break
 			}
 		}
 	}
 	return currentEnums
 }
 
-func dbmlSplitByModelPath(dbml *core.DBML) DBMLDjango {
+func dbmlSplitByModelPath(s *schema.Schema, diag *common.Diagnostics) DBMLDjango {
 	files := map[string]*PythonFile{}
-	for _, table := range dbml.Tables {
-		settings := parseTableSettings(table)
-		if !settings.Hidden {
+	for _, table := range s.Tables {
+		settings := parseTableSettings(table, diag)
+		if !settings.Hidden && settings.ModelPath != "" {
 			djangoTable := DjangoTable{Table: table, Settings: settings}
 			if file, ok := files[settings.ModelPath]; ok {
 				file.Tables = append(file.Tables, djangoTable)
-				file.Enums = addEnums(file.Enums, dbml.Enums, table)
+				file.Enums = addEnums(file.Enums, s.Enums, table)
 			} else {
 				files[settings.ModelPath] = &PythonFile{
 					FilePath: settings.ModelPath,
 					Tables:   []DjangoTable{djangoTable},
-					Enums:    addEnums([]core.Enum{}, dbml.Enums, table),
+					Enums:    addEnums(nil, s.Enums, table),
 				}
 			}
 		}
@@ -262,11 +259,46 @@ func dbmlSplitByModelPath(dbml *core.DBML) DBMLDjango {
 	return dbmlDjango
 }
 
-func CreateDjangoFiles(dbml *core.DBML, djangoRoot string) {
-	dbmlDjango := dbmlSplitByModelPath(dbml)
+// options of the generator
+const OAppLabel = "app_label"
+
+// Generator creates Django models. Tables are split into files by the `model_path` setting of their note.
+type Generator struct{}
 
+func init() {
+	common.Register(Generator{})
+}
+
+func (Generator) Name() string {
+	return "django"
+}
+
+func (Generator) Description() string {
+	return "Creates Django models"
+}
+
+func (Generator) Options() []common.Option {
+	return []common.Option{
+		{Name: OAppLabel, Description: "Adds app_label to the Meta class of every model"},
+	}
+}
+
+func (Generator) Generate(ctx *common.Context) ([]common.File, error) {
+	dbmlDjango := dbmlSplitByModelPath(ctx.Schema, ctx.Diagnostics)
+
+	var files []common.File
 	for _, file := range dbmlDjango.Files {
-		djangoString := dbmlToDjangoString(file, djangoRoot)
-		common.WriteToFile(djangoString, filepath.Join(djangoRoot, file.FilePath))
+		djangoString := dbmlToDjangoString(file, ctx)
+		files = append(files, common.File{Path: file.FilePath, Content: djangoString})
 	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// CreateDjangoFiles creates the Django models of dbml in djangoRoot.
+//
+// Deprecated: use the generator registered as "django" (common.Lookup), it returns the files and reports the
+// warnings of the schema.
+func CreateDjangoFiles(dbml *core.DBML, djangoRoot string) error {
+	return common.CreateFiles("django", dbml, djangoRoot)
 }
@@ -1,5 +1,26 @@
 package dbmlent
 
+// modules of ent (option OModule). The module moved from github.com/facebook/ent (up to v0.5) to entgo.io/ent.
+const (
+	ModuleEntgo    = "entgo.io/ent"
+	ModuleFacebook = "github.com/facebook/ent"
+)
+
+// packages are the import paths of the package qualifiers used in the schemas, paths that start with "ent" are
+// packages of the module of ent (see entPath)
+var packages = map[string]string{
+	"ent":     "ent",
+	"field":   "ent/schema/field",
+	"edge":    "ent/schema/edge",
+	"index":   "ent/schema/index",
+	"decimal": "github.com/shopspring/decimal",
+	"time":    "time",
+	"schema":  "ent/schema",
+	"entsql":  "ent/dialect/entsql",
+	"dialect": "ent/dialect",
+	"mixin":   "ent/schema/mixin",
+}
+
 const entTemplate = `package schema
 
 %v
@@ -22,6 +43,40 @@ func (%v) Edges() []ent.Edge {
 }
 `
 
+const indexesTemplate = `
+// Indexes of the %v.
+func (%v) Indexes() []ent.Index {
+	return %v
+}
+`
+
+const annotationsTemplate = `
+// Annotations of the %v.
+func (%v) Annotations() []schema.Annotation {
+	return %v
+}
+`
+
+const mixinMethodTemplate = `
+// Mixin of the %v.
+func (%v) Mixin() []ent.Mixin {
+	return %v
+}
+`
+
+const mixinTemplate = `
+// %v holds the fields that several schemas share.
+type %v struct {
+	mixin.Schema
+}
+
+// Fields of the %v.
+func (%v) Fields() []ent.Field {
+	return []ent.Field{
+%v	}
+}
+`
+
 const edgeTemplateTo = `
 		edge.To("%v", %v.Type).
 			StorageKey(edge.Column("%v"))%v,
@@ -32,3 +87,10 @@ const edgeTemplateFrom = `
 			Ref("%v").
 			Unique()%v,
 `
+
+const edgeTemplateThrough = `
+		edge.To("%v", %v.Type).
+			Unique().
+			Required().
+			Field("%v"),
+`
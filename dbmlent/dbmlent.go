@@ -3,48 +3,52 @@ package dbmlent
 import (
 	"fmt"
 	"github.com/duythinht/dbml-go/core"
-	"github.com/gobeam/stringy"
 	"github.com/shifty11/dbml-convert/common"
-	"github.com/stretchr/stew/slice"
-	"path/filepath"
-	"regexp"
+	"github.com/shifty11/dbml-convert/schema"
 	"sort"
 	"strings"
+	"unicode"
 )
 
-var entRe = regexp.MustCompile(`ent:"([a-zA-Z0-9-_;:<>= ./']*)"`)
-
 type TableSettings struct {
 	Hidden bool
+	// TableName overrides the name of the table in the database (ent:`table=...`)
+	TableName string
+	// Mixins are the mixins of the schema (ent:`mixin=...`), see parseMixin
+	Mixins []string
 }
 
-func parseTableSettings(table core.Table) TableSettings {
+func parseTableSettings(table *schema.Table, diag *common.Diagnostics) TableSettings {
 	settings := TableSettings{Hidden: false}
 
-	match := entRe.FindStringSubmatch(table.Note)
-	if len(match) == 2 {
-		for _, entry := range strings.Split(match[1], ";") {
-			if entry == common.SHidden {
-				return TableSettings{Hidden: true}
-			}
+	for _, entry := range table.Settings.Only(schema.TargetEnt) {
+		if entry == common.SHidden {
+			return TableSettings{Hidden: true}
+		} else if strings.HasPrefix(entry, common.STable+"=") {
+			settings.TableName = strings.TrimPrefix(entry, common.STable+"=")
+			continue
+		} else if strings.HasPrefix(entry, "mixin=") {
+			settings.Mixins = append(settings.Mixins, strings.TrimPrefix(entry, "mixin="))
+			continue
 		}
+		diag.Warnf(table.Name, "", "ignored table setting %v", entry)
 	}
 	return settings
 }
 
-func getImport(table core.Table, fieldsString string, declarations string) string {
-	var imports = []string{"github.com/facebook/ent", "github.com/facebook/ent/schema/field"}
+func getImport(table *schema.Table, fieldsString string, declarations string, module string) string {
+	var imports = []string{entPath(packages["ent"], module), entPath(packages["field"], module)}
 	hasEdges := false
 	for _, column := range table.Columns {
-		if column.Settings.Note != common.SHidden {
-			if column.Settings.Ref.Type == core.None {
+		if column.Note != common.SHidden {
+			if column.Ref == nil {
 				hasEdges = true
 				break
 			}
 		}
 	}
 	if hasEdges {
-		imports = append(imports, "github.com/facebook/ent/schema/edge")
+		imports = append(imports, entPath(packages["edge"], module))
 	}
 	if strings.Contains(declarations, "decimal.Decimal") {
 		imports = append(imports, "github.com/shopspring/decimal")
@@ -64,9 +68,9 @@ func getImport(table core.Table, fieldsString string, declarations string) strin
 	return str
 }
 
-func hasDecimal(table core.Table) bool {
+func hasDecimal(table *schema.Table) bool {
 	for _, column := range table.Columns {
-		if column.Settings.Note != common.SHidden {
+		if column.Note != common.SHidden {
 			if column.Type == common.TDecimal {
 				return true
 			}
@@ -75,34 +79,118 @@ func hasDecimal(table core.Table) bool {
 	return false
 }
 
-var isDecimalDeclared = false
+// generator holds the state of one run of the generator, runs for different contexts are independent of each other
+type generator struct {
+	ctx       *common.Context
+	relations []*manyToMany
+	settings  map[*schema.Table]TableSettings
+	// fields are the fields of the columns of the schemas, empty for columns that are no field
+	fields map[*schema.Column]string
+	// mixins are the mixins of all schemas, tableMixins the mixins of every schema and mixedIn the columns whose
+	// field is part of a mixin
+	mixins      []*mixin
+	tableMixins map[*schema.Table][]*mixin
+	mixedIn     map[*schema.Column]bool
+	// decimalDeclared is set once a file declares dec, the GoType of decimal fields of all schemas
+	decimalDeclared bool
+}
+
+func newGenerator(ctx *common.Context) *generator {
+	g := &generator{
+		ctx:         ctx,
+		relations:   manyToManyRelations(ctx),
+		settings:    map[*schema.Table]TableSettings{},
+		fields:      map[*schema.Column]string{},
+		tableMixins: map[*schema.Table][]*mixin{},
+		mixedIn:     map[*schema.Column]bool{},
+	}
+	for _, table := range ctx.Schema.Tables {
+		g.settings[table] = parseTableSettings(table, ctx.Diagnostics)
+		if !g.hasSchema(table) {
+			continue
+		}
+		for _, column := range table.Columns {
+			g.fields[column] = getField(column, ctx, g.relations)
+		}
+	}
+	g.checkModule()
+	g.resolveMixins()
+	return g
+}
+
+// module returns the module of ent the schemas import
+func (g *generator) module() string {
+	return g.ctx.Options.Get(OModule, ModuleFacebook)
+}
+
+// entPath returns the import path of a package of packages in the module of ent, e.g. entgo.io/ent/schema/field for
+// ent/schema/field. Other paths are returned as they are.
+func entPath(importPath string, module string) string {
+	if importPath == "ent" || strings.HasPrefix(importPath, "ent/") {
+		return module + strings.TrimPrefix(importPath, "ent")
+	}
+	return importPath
+}
+
+// checkModule reports an unknown module of ent and edge schemas, which need entgo.io/ent
+func (g *generator) checkModule() {
+	switch g.module() {
+	case ModuleEntgo:
+	case ModuleFacebook:
+		if g.ctx.Options.Get(OThrough, "false") == "true" {
+			g.ctx.Diagnostics.Errorf("", "", "option %v=true needs %v=%v, edge schemas are not supported by %v",
+				OThrough, OModule, ModuleEntgo, ModuleFacebook)
+		}
+	default:
+		g.ctx.Diagnostics.Errorf("", "", "unknown value %v of option %v (expected %v or %v)", g.module(), OModule,
+			ModuleEntgo, ModuleFacebook)
+	}
+}
 
-func getSpecialDeclarations(table core.Table) string {
-	if !isDecimalDeclared && hasDecimal(table) {
-		isDecimalDeclared = true // just needed once in all files
+// hasSchema reports whether a schema is generated for the table
+func (g *generator) hasSchema(table *schema.Table) bool {
+	return !g.settings[table].Hidden && !isJoinTable(table, g.relations)
+}
+
+func (g *generator) getSpecialDeclarations(table *schema.Table) string {
+	if !g.decimalDeclared && hasDecimal(table) {
+		g.decimalDeclared = true // just needed once in all files
 		return "var dec decimal.Decimal"
 	}
 	return ""
 }
 
-func getFields(table core.Table, dbml *core.DBML) string {
+// getField returns the field of a column or an empty string if the column is no field, e.g. a foreign key that is
+// generated as edge
+func getField(column *schema.Column, ctx *common.Context, relations []*manyToMany) string {
+	settings := column.Settings.Get(schema.TargetEnt)
+	if column.Settings.Has(schema.TargetEnt, common.SHidden) ||
+		column.Settings.Has(schema.TargetEnt, common.SBackref) ||
+		(column.Ref != nil && !isThroughKey(column, relations)) || isDeclaring(column, relations) {
+		return ""
+	}
+	if isThroughKey(column, relations) {
+		required := *column // the edge of the key is required
+		required.Null = false
+		column = &required
+	}
+	columnType := ctx.ColumnType(typeMap, column)
+	if columnType == "" {
+		return getEnumField(column, ctx.Diagnostics)
+	}
+	return fmt.Sprintf("\t\t%v(\"%v\")%v%v,\n",
+		columnType, column.SnakeName(), getFieldExtras(column, column.SnakeName()), formatSettings(settings))
+}
+
+// getFields returns the fields of the table, fields of its mixins are left out
+func (g *generator) getFields(table *schema.Table) string {
 	if len(table.Columns) == 0 {
 		return "nil"
 	}
 	fields := "[]ent.Field{\n"
 	for _, column := range table.Columns {
-		settings := common.GetNoteSettings(column.Settings.Note, common.EntSettings)
-		if !slice.Contains(settings, common.SHidden) &&
-			!slice.Contains(settings, common.SBackref) &&
-			column.Settings.Ref.Type == core.None {
-			columnType := typeMap[column.Type]
-			if columnType == "" {
-				fields += getEnumField(column, dbml)
-			} else {
-				columnName := strings.ToLower(stringy.New(column.Name).SnakeCase("?", "").Get())
-				fields += fmt.Sprintf("\t\t%v(\"%v\")%v%v,\n",
-					columnType, columnName, getFieldExtras(column), formatSettings(settings))
-			}
+		if !g.mixedIn[column] {
+			fields += g.fields[column]
 		}
 	}
 	fields += "\t}"
@@ -125,68 +213,88 @@ func formatSettings(settings []string) string {
 	return ""
 }
 
-func getEnumField(column core.Column, dbml *core.DBML) string {
-	for _, enum := range dbml.Enums {
-		if strings.ToLower(enum.Name) == strings.ToLower(column.Type) {
-			columnName := strings.ToLower(stringy.New(enum.Name).SnakeCase("?", "").Get())
-			var enumValues []string
-			for _, value := range enum.Values {
-				enumValues = append(enumValues, `"`+value.Name+`"`)
-			}
-			valuesStr := fmt.Sprintf("Values(%v)", strings.Join(enumValues, ", "))
-			return fmt.Sprintf("\t\tfield.Enum(\"%v\").\n\t\t\t%v%v,\n", columnName, valuesStr, getFieldExtras(column))
-		}
+func getEnumField(column *schema.Column, diag *common.Diagnostics) string {
+	if column.Enum == nil {
+		diag.Errorf(column.Table.Name, column.Name, "unknown column type or enum %v", column.Type)
+		return ""
+	}
+	columnName := schema.SnakeCase(column.Enum.Name)
+	var enumValues []string
+	for _, value := range column.Enum.Values {
+		enumValues = append(enumValues, `"`+value.Name+`"`)
 	}
-	panic(fmt.Sprintf("unknown field type: %v", column.Type))
+	valuesStr := fmt.Sprintf("Values(%v)", strings.Join(enumValues, ", "))
+	return fmt.Sprintf("\t\tfield.Enum(\"%v\").\n\t\t\t%v%v,\n", columnName, valuesStr,
+		getFieldExtras(column, columnName))
 }
 
-func getFieldExtras(column core.Column) string {
+// getFieldExtras returns the options of the field name of a column. The column keeps its dbml name in the database
+// (StorageKey), types with parameters like varchar(255) are passed to Postgres (SchemaType) and the text of the note
+// becomes the comment of the field.
+func getFieldExtras(column *schema.Column, name string) string {
 	extras := ""
 	if column.Type == common.TDecimal {
 		extras += ".\n\t\t\tGoType(&dec)"
 	}
-	if column.Settings.Null {
+	if column.Null {
 		extras += ".\n\t\t\tOptional()"
 	}
-	if column.Settings.Unique {
+	if column.Unique {
 		extras += ".\n\t\t\tUnique()"
 	}
-	if column.Settings.Default != "" {
-		extras += ".\n\t\t\tDefault(" + column.Settings.Default + ")"
+	if column.Default != "" {
+		extras += ".\n\t\t\tDefault(" + column.Default + ")"
+	}
+	if column.Name != name {
+		extras += fmt.Sprintf(".\n\t\t\tStorageKey(%q)", column.Name)
+	}
+	if len(column.TypeParams) > 0 {
+		extras += fmt.Sprintf(".\n\t\t\tSchemaType(map[string]string{\n\t\t\t\tdialect.Postgres: %q,\n\t\t\t})",
+			schemaType(column))
+	}
+	if note := schema.NoteText(column.Note); note != "" {
+		extras += fmt.Sprintf(".\n\t\t\tComment(%q)", note)
 	}
 	return extras
 }
 
-func getEdges(table core.Table) string {
+// schemaType returns the SQL type of a column with its parameters, e.g. "varchar(255)"
+func schemaType(column *schema.Column) string {
+	str := column.DBType
+	if len(column.TypeParams) > 0 {
+		str += "(" + strings.Join(column.TypeParams, ",") + ")"
+	}
+	if column.List {
+		str += "[]"
+	}
+	return str
+}
+
+func getEdges(table *schema.Table, relations []*manyToMany) string {
 	var edges []string
 	for _, column := range table.Columns {
-		if column.Settings.Note != common.SHidden {
-			if column.Settings.Ref.Type == core.ManyToOne || column.Settings.Ref.Type == core.OneToOne {
-				split := strings.Split(column.Settings.Ref.To, ".")
-				ref := strings.ToLower(stringy.New(split[len(split)-1]).SnakeCase("?", "").Get())
-				fromName := strings.ToLower(stringy.New(column.Name).SnakeCase("?", "").Get())
+		if column.Note != common.SHidden {
+			if isThroughKey(column, relations) {
+				edges = append(edges, fmt.Sprintf(edgeTemplateThrough, edgeName(column), column.Ref.To.Table.Name,
+					column.SnakeName()))
+			} else if column.Ref != nil {
 				options := ""
-				if !column.Settings.Null {
+				if !column.Null {
 					options = ".\n\t\t\tRequired()"
 				}
-				edges = append(edges, fmt.Sprintf(edgeTemplateFrom, fromName, column.Type, ref, options))
-			} else {
-				params := common.GetNoteSettings(column.Settings.Note, common.EntSettings)
-				if slice.Contains(params, common.SBackref) {
-					toName := strings.ToLower(stringy.New(column.Name).SnakeCase("?", "").Get())
-					edgeType := column.Type
-					options := ""
-					if strings.HasPrefix(edgeType, "[]") {
-						edgeType = edgeType[2:]
-					} else {
-						options = ".\n\t\t\tUnique()"
-					}
-					ref := strings.ToLower(stringy.New(table.Name).SnakeCase("?", "").Get()) + "_id"
-					edges = append(edges, fmt.Sprintf(edgeTemplateTo, toName, edgeType, ref, options))
+				edges = append(edges, fmt.Sprintf(edgeTemplateFrom, column.SnakeName(), column.Ref.To.Table.Name,
+					column.Ref.To.SnakeName(), options))
+			} else if column.Settings.Has(schema.TargetEnt, common.SBackref) {
+				options := ""
+				if !column.List {
+					options = ".\n\t\t\tUnique()"
 				}
+				ref := table.SnakeName() + "_id"
+				edges = append(edges, fmt.Sprintf(edgeTemplateTo, column.SnakeName(), column.Type, ref, options))
 			}
 		}
 	}
+	edges = append(edges, manyToManyEdges(table, relations)...)
 	if len(edges) == 0 {
 		return "nil"
 	}
@@ -198,27 +306,199 @@ func getEdges(table core.Table) string {
 	return str
 }
 
-func dbmlTableToEntString(table core.Table, dbml *core.DBML) string {
-	settings := parseTableSettings(table)
-	if settings.Hidden {
+// getIndexes returns the indexes of the table, columns with a reference are added as edges. Indexes ent can not
+// express are reported as warnings and skipped, the primary key of an edge schema is its id (see
+// getThroughAnnotation).
+func getIndexes(table *schema.Table, diag *common.Diagnostics, relations []*manyToMany) string {
+	var indexes []string
+	for _, index := range table.Indexes {
+		if index.PK {
+			if len(index.Columns) > 1 && !isThroughKey(index.Columns[0], relations) {
+				diag.Warnf(table.Name, "", "composite primary key %v is skipped, ent can not express it", index.Describe())
+			}
+			continue
+		}
+		if len(index.Expressions) > 0 {
+			diag.Warnf(table.Name, "", "index %v is skipped, ent can not express expressions", index.Describe())
+			continue
+		}
+		var fields, edges []string
+		for _, column := range index.Columns {
+			if column.Ref != nil && column.Note != common.SHidden {
+				edges = append(edges, `"`+column.SnakeName()+`"`)
+			} else if !column.IsVirtual() && !column.Settings.Has(schema.TargetEnt, common.SHidden) {
+				fields = append(fields, `"`+column.SnakeName()+`"`)
+			} else {
+				diag.Warnf(table.Name, column.Name, "index %v is skipped, the column is no field or edge of the schema",
+					index.Describe())
+				fields = nil
+				edges = nil
+				break
+			}
+		}
+		if len(fields) == 0 && len(edges) == 0 {
+			continue
+		}
+		str := "\n\t\tindex."
+		if len(fields) > 0 {
+			str += fmt.Sprintf("Fields(%v)", strings.Join(fields, ", "))
+			if len(edges) > 0 {
+				str += ".\n\t\t\t"
+			}
+		}
+		if len(edges) > 0 {
+			str += fmt.Sprintf("Edges(%v)", strings.Join(edges, ", "))
+		}
+		if index.Unique {
+			str += ".\n\t\t\tUnique()"
+		}
+		if index.Name != "" {
+			str += fmt.Sprintf(".\n\t\t\tStorageKey(%q)", index.Name)
+		}
+		if index.Type != "" {
+			diag.Warnf(table.Name, "", "type %v of index %v is ignored, ent can not express it", index.Type,
+				index.Describe())
+		}
+		indexes = append(indexes, str+",")
+	}
+	if len(indexes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(indexesTemplate, table.Name, table.Name, "[]ent.Index{"+strings.Join(indexes, "")+"\n\t}")
+}
+
+// entSnake converts a name into snake case like ent does for table names, e.g. "user_info" for "UserInfo" and
+// "http_server" for "HTTPServer"
+func entSnake(name string) string {
+	var b strings.Builder
+	j := 0 // position of the last underscore
+	for i := 0; i < len(name); i++ {
+		r := rune(name[i])
+		if i > 0 && i < len(name)-1 && unicode.IsUpper(r) {
+			if unicode.IsLower(rune(name[i-1])) ||
+				j != i-1 && unicode.IsLower(rune(name[i+1])) && unicode.IsLetter(rune(name[i-1])) {
+				j = i
+				b.WriteString("_")
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// defaultTableName returns the name ent gives the table of a schema if no name is annotated, the snake case plural of
+// the name of the schema
+func defaultTableName(table *schema.Table) string {
+	return entSnake(schema.Plural(table.Name))
+}
+
+// getAnnotations returns the annotations of the table or an empty string: the name of its table in the database and
+// the id of an edge schema. The name is only annotated if it is set in the note of the table or differs from the name
+// ent chooses itself, so that the tables of existing schemas keep their names.
+func getAnnotations(table *schema.Table, settings TableSettings, ctx *common.Context, relations []*manyToMany) string {
+	annotations := ""
+	tableName, explicit := settings.TableName, settings.TableName != ""
+	if !explicit {
+		_, explicit = common.TableOverride(table)
+		tableName = ctx.Naming.TableName(table)
+	}
+	if explicit || tableName != defaultTableName(table) {
+		annotations += fmt.Sprintf("\n\t\tentsql.Annotation{Table: %q},", tableName)
+	}
+	if through := getThroughAnnotation(table, relations); through != "" {
+		annotations += "\n\t\t" + through + ","
+	}
+	if annotations == "" {
 		return ""
 	}
-	fields := getFields(table, dbml)
-	specialDeclarations := getSpecialDeclarations(table)
-	str := fmt.Sprintf(entTemplate, getImport(table, fields, specialDeclarations),
+	return fmt.Sprintf(annotationsTemplate, table.Name, table.Name, "[]schema.Annotation{"+annotations+"\n\t}")
+}
+
+func (g *generator) dbmlTableToEntString(table *schema.Table) string {
+	ctx, relations, settings := g.ctx, g.relations, g.settings[table]
+	if !g.hasSchema(table) {
+		return ""
+	}
+	fields := g.getFields(table)
+	specialDeclarations := g.getSpecialDeclarations(table)
+	str := fmt.Sprintf(entTemplate, getImport(table, fields, specialDeclarations, g.module()),
 		table.Name, table.Name, table.Name,
-		specialDeclarations,
+		specialDeclarations+g.getMixin(table),
 		table.Name, table.Name,
 		fields,
 		table.Name, table.Name,
-		getEdges(table),
+		getEdges(table, relations),
 	)
-	return str
+	return str + getIndexes(table, ctx.Diagnostics, relations) + getAnnotations(table, settings, ctx, relations)
+}
+
+// Generator creates Ent schemas, one file per table. The state of a run is kept in a generator, so Generate can be
+// called concurrently for different contexts.
+type Generator struct{}
+
+func init() {
+	common.Register(Generator{})
+}
+
+func (Generator) Name() string {
+	return "ent"
+}
+
+func (Generator) Description() string {
+	return "Creates Ent models"
 }
 
-func CreateEntFiles(dbml *core.DBML, outputPath string) {
-	for _, table := range dbml.Tables {
-		str := dbmlTableToEntString(table, dbml)
-		common.WriteToFile(str, filepath.Join(outputPath, strings.ToLower(table.Name)+".go"))
+// options of the generator
+const (
+	// OThrough generates join tables with other columns as edge schemas (Through)
+	OThrough = "through"
+	// OModule is the module of ent whose packages the schemas import, ModuleFacebook or ModuleEntgo
+	OModule = "module"
+	// OMixins is the number of tables that have to share fields to factor them into a mixin, 0 disables it
+	OMixins = "mixins"
+)
+
+func (Generator) Options() []common.Option {
+	return []common.Option{
+		{Name: OThrough, Description: "true to generate join tables with other columns as edge schemas of " +
+			"many-to-many edges", Default: "false"},
+		{Name: OModule, Description: "module of ent the schemas import, " + ModuleFacebook + " (up to v0.5, " +
+			"without edge schemas) or " + ModuleEntgo, Default: ModuleFacebook},
+		{Name: OMixins, Description: "number of tables that have to share at least two fields to factor them " +
+			"into a generated mixin, 0 to disable it", Default: "0"},
 	}
 }
+
+func (Generator) Generate(ctx *common.Context) ([]common.File, error) {
+	var files []common.File
+	g := newGenerator(ctx)
+	for _, table := range ctx.Schema.Tables {
+		str := g.dbmlTableToEntString(table)
+		if str == "" {
+			continue
+		}
+		formatted, err := common.FormatGo(str, g.packages())
+		if err != nil {
+			ctx.Diagnostics.Errorf(table.Name, "", "generated Go code does not parse: %v", err)
+			formatted = str
+		}
+		files = append(files, common.File{Path: strings.ToLower(table.Name) + ".go", Content: formatted})
+	}
+	if str := g.getMixins(); str != "" {
+		formatted, err := common.FormatGo(str, g.packages())
+		if err != nil {
+			ctx.Diagnostics.Errorf("", "", "generated mixins do not parse: %v", err)
+			formatted = str
+		}
+		files = append(files, common.File{Path: "mixin.go", Content: formatted})
+	}
+	return files, nil
+}
+
+// CreateEntFiles creates the Ent schemas of dbml in outputPath.
+//
+// Deprecated: use the generator registered as "ent" (common.Lookup), it returns the files and reports the
+// warnings of the schema.
+func CreateEntFiles(dbml *core.DBML, outputPath string) error {
+	return common.CreateFiles("ent", dbml, outputPath)
+}
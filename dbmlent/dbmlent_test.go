@@ -0,0 +1,81 @@
+package dbmlent
+
+import (
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// buildSchema builds the schema of a dbml source like the command line does
+func buildSchema(t *testing.T, src string) *schema.Schema {
+	t.Helper()
+	document, err := common.ParseDocument("test.dbml", []byte(src))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	s := schema.Build(document.DBML, &common.Diagnostics{})
+	s.ApplyExtensions(document.Extensions)
+	return s
+}
+
+// generate runs the generator on the schema, every run gets its own diagnostics
+func generate(t *testing.T, s *schema.Schema, options common.Options) ([]common.File, *common.Diagnostics) {
+	t.Helper()
+	ctx := common.NewContext(s, "testdata/out", options)
+	files, err := Generator{}.Generate(ctx)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	return files, ctx.Diagnostics
+}
+
+const reentrantSchema = `Table users {
+  id int [pk, increment]
+  balance decimal(10,2) [not null]
+  created_at datetime [note: 'all:` + "`CreatedAt`" + `']
+  updated_at datetime [note: 'all:` + "`UpdatedAt`" + `']
+}
+Table orders {
+  id int [pk, increment]
+  user_id int [ref: > users.id]
+  total decimal(10,2)
+  created_at datetime [note: 'all:` + "`CreatedAt`" + `']
+  updated_at datetime [note: 'all:` + "`UpdatedAt`" + `']
+}
+Table tags {
+  id int [pk]
+  order_ids int [ref: <> orders.id]
+}
+`
+
+// TestGenerateReentrant checks that runs on the same schema create the same files, one after the other and
+// concurrently (go test -race)
+func TestGenerateReentrant(t *testing.T) {
+	s := buildSchema(t, reentrantSchema)
+	options := common.Options{OMixins: "2"}
+	want, _ := generate(t, s, options)
+	if len(want) == 0 {
+		t.Fatal("Generate() created no files")
+	}
+	if got, _ := generate(t, s, options); !reflect.DeepEqual(got, want) {
+		t.Errorf("second Generate() = %v, want %v", got, want)
+	}
+	results := make([][]common.File, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := common.NewContext(s, "testdata/out", options)
+			results[i], _ = Generator{}.Generate(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for i, got := range results {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrent Generate() %v = %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package dbmlent
+
+import (
+	"flag"
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// golden returns the diagnostics and the files of a run in the format of the golden files
+func golden(files []common.File, diag *common.Diagnostics) string {
+	var b strings.Builder
+	for _, d := range diag.List() {
+		fmt.Fprintf(&b, "%v: %v\n", d.Severity, d.Error)
+	}
+	for _, file := range files {
+		fmt.Fprintf(&b, "-- %v --\n%v", file.Path, file.Content)
+	}
+	return b.String()
+}
+
+// TestGolden generates the schemas of testdata/<dbml>.dbml and compares them with testdata/<name>.golden, go test
+// -update rewrites the golden files
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbml    string
+		options common.Options
+	}{
+		{"indexes", "indexes", nil},
+		{"mixins", "mixins", common.Options{OMixins: "3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := ioutil.ReadFile(filepath.Join("testdata", test.dbml+".dbml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, diag := generate(t, buildSchema(t, string(src)), test.options)
+			got := golden(files, diag)
+
+			path := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("generated files differ from %v (go test -update rewrites it):\n%v", path,
+					common.UnifiedDiff(path, "generated", string(want), got))
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package dbmlent
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"strings"
+)
+
+// manyToMany is a many-to-many relation between two tables, declared by a <> reference or by a join table
+type manyToMany struct {
+	from, to *schema.Table
+	// name is the name of the edge of from, inverse the name of the edge of to
+	name, inverse string
+	// storage are the options of the StorageKey of the edge, empty for the defaults of ent
+	storage string
+	// join is the join table of the relation, through is set to it if it is generated as edge schema
+	join, through *schema.Table
+	// keys are the foreign keys of the join table
+	keys [2]*schema.Column
+	// declaring are the columns that declare the relation, e.g. "Tags []Tag [ref: <> Tag.ID]"
+	declaring []*schema.Column
+}
+
+// edgeName returns the name of the edge of a foreign key column, e.g. "author" for "author_id"
+func edgeName(column *schema.Column) string {
+	name := column.SnakeName()
+	if trimmed := strings.TrimSuffix(name, "_id"); trimmed != "" {
+		return trimmed
+	}
+	return name
+}
+
+// joinColumns returns the two foreign keys of a join table, a table whose primary key consists of two columns that
+// reference other tables. extra reports whether the table has other columns.
+func joinColumns(table *schema.Table) (from *schema.Column, to *schema.Column, extra bool, ok bool) {
+	var pk []*schema.Column
+	for _, index := range table.Indexes {
+		if index.PK {
+			pk = index.Columns
+		}
+	}
+	if len(pk) == 0 {
+		for _, column := range table.Columns {
+			if column.PK {
+				pk = append(pk, column)
+			}
+		}
+	}
+	if len(pk) != 2 || pk[0].Ref == nil || pk[1].Ref == nil || pk[0].IsVirtual() || pk[1].IsVirtual() {
+		return nil, nil, false, false
+	}
+	for _, column := range table.Columns {
+		if column != pk[0] && column != pk[1] && column.Note != common.SHidden {
+			extra = true
+		}
+	}
+	return pk[0], pk[1], extra, true
+}
+
+// manyToManyRelations returns the many-to-many relations of the schema. Join tables without other columns are part of
+// their relation and get no schema, join tables with other columns become edge schemas if the option OThrough is set.
+func manyToManyRelations(ctx *common.Context) []*manyToMany {
+	var relations []*manyToMany
+	for _, relation := range ctx.Schema.Relations {
+		if relation.Type != schema.ManyToMany {
+			continue
+		}
+		m := &manyToMany{
+			from:    relation.From.Table,
+			to:      relation.To.Table,
+			name:    schema.Plural(relation.To.Table.SnakeName()),
+			inverse: schema.Plural(relation.From.Table.SnakeName()),
+		}
+		for _, column := range []*schema.Column{relation.From, relation.To} {
+			if column.IsVirtual() {
+				m.declaring = append(m.declaring, column)
+			}
+		}
+		if relation.From.IsVirtual() {
+			m.name = relation.From.SnakeName()
+		}
+		if relation.To.IsVirtual() {
+			m.inverse = relation.To.SnakeName()
+		}
+		if m.from == m.to {
+			m.inverse = m.name // a symmetric relation like friends
+		}
+		if relation.Name != "" {
+			m.storage = fmt.Sprintf("edge.Table(%q)", relation.Name)
+		}
+		relations = append(relations, m)
+	}
+	for _, table := range ctx.Schema.Tables {
+		from, to, extra, ok := joinColumns(table)
+		if !ok || (extra && ctx.Options.Get(OThrough, "false") != "true") {
+			continue
+		}
+		m := &manyToMany{
+			from:    from.Ref.To.Table,
+			to:      to.Ref.To.Table,
+			name:    schema.Plural(to.Ref.To.Table.SnakeName()),
+			inverse: schema.Plural(from.Ref.To.Table.SnakeName()),
+			join:    table,
+			keys:    [2]*schema.Column{from, to},
+		}
+		if m.from == m.to {
+			m.name, m.inverse = schema.Plural(edgeName(to)), schema.Plural(edgeName(from))
+		}
+		if extra {
+			m.through = table
+		} else {
+			m.storage = fmt.Sprintf("edge.Table(%q), edge.Columns(%q, %q)", ctx.Naming.TableName(table),
+				from.SnakeName(), to.SnakeName())
+		}
+		relations = append(relations, m)
+	}
+	// edges with the same name, e.g. of two join tables of the same tables, are named after their join table
+	used := map[*schema.Table]map[string]bool{}
+	for _, m := range relations {
+		for _, edge := range []struct {
+			table *schema.Table
+			name  *string
+		}{{m.from, &m.name}, {m.to, &m.inverse}} {
+			if used[edge.table] == nil {
+				used[edge.table] = map[string]bool{}
+			}
+			if used[edge.table][*edge.name] && m.join != nil {
+				*edge.name = m.join.SnakeName() + "_" + *edge.name
+			}
+			used[edge.table][*edge.name] = true
+		}
+	}
+	return relations
+}
+
+// isJoinTable reports whether the table is the join table of a many-to-many relation and gets no schema
+func isJoinTable(table *schema.Table, relations []*manyToMany) bool {
+	for _, m := range relations {
+		if m.join == table && m.through == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isDeclaring reports whether the column declares a many-to-many relation
+func isDeclaring(column *schema.Column, relations []*manyToMany) bool {
+	for _, m := range relations {
+		for _, c := range m.declaring {
+			if c == column {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// manyToManyEdges returns the edges of the many-to-many relations of the table
+func manyToManyEdges(table *schema.Table, relations []*manyToMany) []string {
+	var edges []string
+	for _, m := range relations {
+		options := ""
+		if m.storage != "" {
+			options += fmt.Sprintf(".\n\t\t\tStorageKey(%v)", m.storage)
+		}
+		through := ""
+		if m.through != nil {
+			through = fmt.Sprintf(".\n\t\t\tThrough(%q, %v.Type)", schema.Plural(m.through.SnakeName()), m.through.Name)
+		}
+		if m.from == table && m.to == table {
+			if m.name == m.inverse {
+				// a symmetric relation like friends
+				edges = append(edges, fmt.Sprintf("\n\t\tedge.To(%q, %v.Type)%v%v,\n", m.name, table.Name, options,
+					through))
+			} else {
+				edges = append(edges, fmt.Sprintf("\n\t\tedge.To(%q, %v.Type)%v.\n\t\t\tFrom(%q)%v,\n", m.name,
+					table.Name, options, m.inverse, through))
+			}
+		} else if m.from == table {
+			edges = append(edges, fmt.Sprintf("\n\t\tedge.To(%q, %v.Type)%v%v,\n", m.name, m.to.Name, options, through))
+		} else if m.to == table {
+			edges = append(edges, fmt.Sprintf("\n\t\tedge.From(%q, %v.Type).\n\t\t\tRef(%q)%v,\n", m.inverse,
+				m.from.Name, m.name, through))
+		}
+	}
+	return edges
+}
+
+// isThroughKey reports whether the column is a foreign key of a join table that is generated as edge schema
+func isThroughKey(column *schema.Column, relations []*manyToMany) bool {
+	for _, m := range relations {
+		if m.through != nil && (m.keys[0] == column || m.keys[1] == column) {
+			return true
+		}
+	}
+	return false
+}
+
+// getThroughAnnotation returns the annotation of a join table that is generated as edge schema or an empty string,
+// its id consists of both foreign keys
+func getThroughAnnotation(table *schema.Table, relations []*manyToMany) string {
+	for _, m := range relations {
+		if m.through == table {
+			return fmt.Sprintf("field.ID(%q, %q)", m.keys[0].SnakeName(), m.keys[1].SnakeName())
+		}
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+package dbmlent
+
+import (
+	"fmt"
+	"github.com/gobeam/stringy"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mixin is a mixin of ent schemas. Generated mixins hold the fields that several tables share, mixins of the user
+// are referenced by their import path.
+type mixin struct {
+	// name is the name of the type, path the import path of its package or empty for a generated mixin
+	name, path string
+	// columns are the snake case names of the columns whose fields are part of the mixin, fields their fields
+	columns, fields []string
+	// declared is the table that lists the columns of a generated mixin
+	declared *schema.Table
+}
+
+// ref returns the mixin as it is used in the Mixin method of a schema, e.g. "mixins.SoftDelete{}". The package is
+// imported with the name it is assumed to have (see common.ImportName).
+func (m *mixin) ref() string {
+	if m.path == "" {
+		return m.name + "{}"
+	}
+	return common.ImportName(m.path) + "." + m.name + "{}"
+}
+
+// parseMixin parses the value of a mixin setting: the name of a generated mixin (TimeMixin) or the import path and
+// name of a mixin of the user (github.com/acme/app/ent/mixins.SoftDelete), optionally followed by the columns it
+// holds (TimeMixin:created_at,updated_at). The columns of a generated mixin have to be listed by one table.
+func parseMixin(value string) (name string, importPath string, columns []string, err error) {
+	name = value
+	if i := strings.Index(value, ":"); i >= 0 {
+		name = value[:i]
+		for _, column := range strings.Split(value[i+1:], ",") {
+			if column != "" {
+				columns = append(columns, schema.SnakeCase(column))
+			}
+		}
+	}
+	if i := strings.LastIndex(name, "."); i > strings.LastIndex(name, "/") {
+		importPath, name = name[:i], name[i+1:]
+	}
+	if !token.IsIdentifier(name) || (importPath != "" && !token.IsIdentifier(common.ImportName(importPath))) {
+		return "", "", nil, fmt.Errorf("mixin %v is no Go type like TimeMixin or github.com/acme/app/mixins.Time",
+			value)
+	}
+	return name, importPath, columns, nil
+}
+
+// mixinName returns the name of a generated mixin of the columns, e.g. CreatedAtUpdatedAtMixin
+func mixinName(columns []string) string {
+	name := ""
+	for _, column := range columns {
+		part := stringy.New(column).CamelCase("?", "")
+		if strings.HasSuffix(part, "Id") {
+			part = strings.TrimSuffix(part, "Id") + "ID"
+		}
+		name += part
+	}
+	return name + "Mixin"
+}
+
+// fieldColumn returns the column of a field of the table or nil if the table has no such field
+func (g *generator) fieldColumn(table *schema.Table, name string) *schema.Column {
+	for _, column := range table.Columns {
+		if column.SnakeName() == name && g.fields[column] != "" {
+			return column
+		}
+	}
+	return nil
+}
+
+// resolveMixins assigns the mixins of the settings of the tables and, if the option OMixins is set, the mixins of
+// fields several tables share to the schemas
+func (g *generator) resolveMixins() {
+	diag := g.ctx.Diagnostics
+	byName := map[string]*mixin{}
+	for _, table := range g.ctx.Schema.Tables {
+		if !g.hasSchema(table) {
+			continue
+		}
+		for _, value := range g.settings[table].Mixins {
+			name, importPath, columns, err := parseMixin(value)
+			if err != nil {
+				diag.Errorf(table.Name, "", "%v", err)
+				continue
+			}
+			m := byName[importPath+"."+name]
+			if m == nil {
+				m = &mixin{name: name, path: importPath}
+				byName[importPath+"."+name] = m
+				g.mixins = append(g.mixins, m)
+			}
+			g.tableMixins[table] = append(g.tableMixins[table], m)
+			if len(columns) == 0 {
+				continue
+			}
+			if importPath != "" {
+				// the fields of the columns are declared by the mixin of the user
+				for _, name := range columns {
+					if column := g.fieldColumn(table, name); column != nil {
+						g.mixedIn[column] = true
+					} else {
+						diag.Warnf(table.Name, name, "column %v of mixin %v is no field of the table", name, value)
+					}
+				}
+			} else if m.declared == nil {
+				m.declared = table
+				for _, name := range columns {
+					if column := g.fieldColumn(table, name); column != nil {
+						m.columns = append(m.columns, name)
+						m.fields = append(m.fields, g.fields[column])
+					} else {
+						diag.Errorf(table.Name, name, "column %v of mixin %v is no field of the table", name, m.name)
+					}
+				}
+			} else if strings.Join(columns, ",") != strings.Join(m.columns, ",") {
+				diag.Errorf(table.Name, "", "mixin %v is declared with the columns %v by table %v", m.name,
+					strings.Join(m.columns, ","), m.declared.Name)
+			}
+		}
+	}
+	for _, table := range g.ctx.Schema.Tables {
+		for _, m := range g.tableMixins[table] {
+			if m.path != "" {
+				continue
+			}
+			if m.declared == nil {
+				diag.Errorf(table.Name, "", "mixin %v has no columns, list them in the note of one table "+
+					"(ent:`mixin=%v:created_at,updated_at`)", m.name, m.name)
+				continue
+			}
+			for i, name := range m.columns {
+				if column := g.fieldColumn(table, name); column != nil {
+					if g.fields[column] != m.fields[i] {
+						diag.Warnf(table.Name, column.Name, "the field differs from the field of mixin %v, which "+
+							"is used", m.name)
+					}
+					g.mixedIn[column] = true
+				}
+			}
+		}
+	}
+	value := g.ctx.Options.Get(OMixins, "0")
+	if minTables, err := strconv.Atoi(value); err != nil || minTables == 1 || minTables < 0 {
+		diag.Errorf("", "", "unknown value %v of option %v (expected 0 or a number of tables of at least 2)", value,
+			OMixins)
+	} else if minTables > 0 {
+		g.detectMixins(minTables, byName)
+	}
+	g.checkMixinPackages()
+}
+
+// detectMixins creates a mixin for every group of at least two fields that at least minTables tables share
+func (g *generator) detectMixins(minTables int, byName map[string]*mixin) {
+	type sharedField struct {
+		field   string
+		tables  []*schema.Table
+		columns []*schema.Column
+	}
+	var shared []*sharedField
+	byField := map[string]*sharedField{}
+	for _, table := range g.ctx.Schema.Tables {
+		if !g.hasSchema(table) {
+			continue
+		}
+		for _, column := range table.Columns {
+			field := g.fields[column]
+			if field == "" || g.mixedIn[column] {
+				continue
+			}
+			s := byField[field]
+			if s == nil {
+				s = &sharedField{field: field}
+				byField[field] = s
+				shared = append(shared, s)
+			}
+			s.tables = append(s.tables, table)
+			s.columns = append(s.columns, column)
+		}
+	}
+	// fields that are shared by the same tables form a group
+	var groups [][]*sharedField
+	byTables := map[string]int{}
+	for _, s := range shared {
+		if len(s.tables) < minTables {
+			continue
+		}
+		var names []string
+		for _, table := range s.tables {
+			names = append(names, table.Name)
+		}
+		key := strings.Join(names, ",")
+		if i, ok := byTables[key]; ok {
+			groups[i] = append(groups[i], s)
+		} else {
+			byTables[key] = len(groups)
+			groups = append(groups, []*sharedField{s})
+		}
+	}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		m := &mixin{declared: group[0].tables[0]}
+		for _, s := range group {
+			m.columns = append(m.columns, s.columns[0].SnakeName())
+			m.fields = append(m.fields, s.field)
+			for _, column := range s.columns {
+				g.mixedIn[column] = true
+			}
+		}
+		m.name = mixinName(m.columns)
+		if byName["."+m.name] != nil {
+			g.ctx.Diagnostics.Errorf("", "", "mixin %v is generated for shared fields but is declared by a table",
+				m.name)
+		}
+		byName["."+m.name] = m
+		g.mixins = append(g.mixins, m)
+		for _, table := range group[0].tables {
+			g.tableMixins[table] = append(g.tableMixins[table], m)
+		}
+	}
+}
+
+// checkMixinPackages reports mixins of the user whose package name is used by another package
+func (g *generator) checkMixinPackages() {
+	used := map[string]string{}
+	for name, importPath := range packages {
+		used[name] = entPath(importPath, g.module())
+	}
+	for _, m := range g.mixins {
+		if m.path == "" {
+			continue
+		}
+		name := common.ImportName(m.path)
+		if importPath, ok := used[name]; ok && importPath != m.path {
+			g.ctx.Diagnostics.Errorf("", "", "package %v of mixin %v has the same name as %v", m.path, m.name,
+				importPath)
+		}
+		used[name] = m.path
+	}
+}
+
+// packages returns the import paths of the package qualifiers used in the schemas including the packages of the
+// mixins of the user
+func (g *generator) packages() map[string]string {
+	all := map[string]string{}
+	for name, importPath := range packages {
+		all[name] = entPath(importPath, g.module())
+	}
+	for _, m := range g.mixins {
+		if m.path != "" {
+			all[common.ImportName(m.path)] = m.path
+		}
+	}
+	return all
+}
+
+// getMixin returns the Mixin method of the table or an empty string if it has no mixins
+func (g *generator) getMixin(table *schema.Table) string {
+	if len(g.tableMixins[table]) == 0 {
+		return ""
+	}
+	var refs []string
+	for _, m := range g.tableMixins[table] {
+		refs = append(refs, "\n\t\t"+m.ref()+",")
+	}
+	return fmt.Sprintf(mixinMethodTemplate, table.Name, table.Name, "[]ent.Mixin{"+strings.Join(refs, "")+"\n\t}")
+}
+
+// getMixins returns the file with the generated mixins or an empty string if there are none
+func (g *generator) getMixins() string {
+	var generated []*mixin
+	for _, m := range g.mixins {
+		if m.path == "" && m.declared != nil {
+			generated = append(generated, m)
+		}
+	}
+	if len(generated) == 0 {
+		return ""
+	}
+	sort.SliceStable(generated, func(i, j int) bool { return generated[i].name < generated[j].name })
+	str := "package schema\n"
+	for _, m := range generated {
+		str += fmt.Sprintf(mixinTemplate, m.name, m.name, m.name, m.name, strings.Join(m.fields, ""))
+	}
+	return str
+}
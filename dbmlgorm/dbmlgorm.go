@@ -4,151 +4,376 @@ import (
 	"fmt"
 	"github.com/duythinht/dbml-go/core"
 	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
 	"github.com/stretchr/stew/slice"
-	"io/ioutil"
-	"path/filepath"
-	"regexp"
 	"strings"
 )
 
-var gormRe = regexp.MustCompile(`gorm:"([a-zA-Z0-9-_;:<>= ./']*)"`)
-
-func getTemplate(path string) string {
-	template, err := ioutil.ReadFile(path)
-	if err != nil {
-		return "// Auto generated models. Do not edit by hand!\n// Instead add the file 'model.go.template' " +
-			"which will be added to the top of 'model.go'\nimport enum\n\nfrom django.db import models\n\n\n"
+// hasPrefix reports whether one of the settings starts with prefix
+func hasPrefix(settings []string, prefix string) bool {
+	for _, setting := range settings {
+		if strings.HasPrefix(setting, prefix) {
+			return true
+		}
 	}
-	return string(template)
+	return false
 }
 
-func dbmlToGormString(dbml *core.DBML, outputPath string) string {
-	str := getTemplate(filepath.Join(outputPath, "model.go.template"))
-	for _, enum := range dbml.Enums {
-		str += dbmlEnumToGormString(enum)
-	}
-	for _, table := range dbml.Tables {
-		str += dbmlTableToGormString(table)
-	}
-	return str
+// header marks the file as generated (see https://golang.org/s/generatedcode)
+const header = common.GoHeader + "\n" +
+	"// Add the file 'model.go.template' to add code after the imports.\n\n"
+
+// packages are the import paths of the package qualifiers used in Go types
+var packages = map[string]string{
+	"time":      "time",
+	"decimal":   "github.com/shopspring/decimal",
+	"gorm":      "gorm.io/gorm",
+	"sql":       "database/sql",
+	"datatypes": "gorm.io/datatypes",
+	"fmt":       "fmt",
+	"json":      "encoding/json",
+	"driver":    "database/sql/driver",
 }
 
-func dbmlEnumToGormString(enum core.Enum) string {
-	str := ""
-	str += fmt.Sprintf("type %v uint\n\nconst (\n", enum.Name)
-	for i, column := range enum.Values {
-		str += fmt.Sprintf("    %v", column.Name)
-		if i == 0 {
-			str += fmt.Sprintf(" = iota")
-		}
-		if column.Note != "" {
-			str += fmt.Sprintf("\t// %v", column.Note)
+// goFile collects the imports of a generated Go file (import path -> explicit name)
+type goFile struct {
+	imports map[string]string
+	// dir is the package of the file
+	dir    string
+	layout *layout
+	// preamble are the comments of the template before the package clause
+	preamble string
+}
+
+// use adds the import of the package of goType, e.g. "time" for "*time.Time"
+func (f *goFile) use(goType string) {
+	goType = strings.TrimLeft(goType, "[]*")
+	if i := strings.Index(goType, "."); i > 0 {
+		if path, ok := packages[goType[:i]]; ok {
+			f.imports[path] = ""
 		}
-		str += "\n"
 	}
-	str += ")\n\n"
-	return str
+}
+
+func getTemplate(path string, ctx *common.Context) string {
+	template, _ := ctx.ReadTemplate(path)
+	if template != "" && !strings.HasSuffix(template, "\n\n") {
+		template = strings.TrimRight(template, "\n") + "\n\n"
+	}
+	return template
 }
 
 type TableSettings struct {
 	Inheritances []string
 	Hidden       bool
+	// TableName overrides the name of the table in the database (gorm:`table=...`)
+	TableName string
+	// Package is the directory of the Go package of the model relative to the output path (gorm:`package=...`)
+	Package string
+	// SoftDelete adds a gorm.DeletedAt field if the table has no soft delete column (gorm:`soft_delete`)
+	SoftDelete bool
 }
 
-func parseTableSettings(table core.Table) TableSettings {
+func parseTableSettings(table *schema.Table, diag *common.Diagnostics) TableSettings {
 	settings := TableSettings{Hidden: false}
 
-	match := gormRe.FindStringSubmatch(table.Note)
-	if len(match) == 2 {
-		for _, entry := range strings.Split(match[1], ";") {
-			if entry == "hidden" {
-				return TableSettings{Hidden: true}
-			} else if strings.HasPrefix(entry, "inherit=") {
-				for _, inhStr := range strings.Split(strings.Replace(entry, "inherit=", "", 1), ";") {
-					settings.Inheritances = append(settings.Inheritances, inhStr)
-				}
+	for _, entry := range table.Settings.Only(schema.TargetGorm) {
+		if entry == common.SHidden {
+			return TableSettings{Hidden: true}
+		} else if strings.HasPrefix(entry, "inherit=") {
+			for _, inhStr := range strings.Split(strings.Replace(entry, "inherit=", "", 1), ";") {
+				settings.Inheritances = append(settings.Inheritances, inhStr)
 			}
+		} else if strings.HasPrefix(entry, common.STable+"=") {
+			settings.TableName = strings.TrimPrefix(entry, common.STable+"=")
+		} else if entry == common.SSoftDelete {
+			settings.SoftDelete = true
+		} else if strings.HasPrefix(entry, "package=") {
+			settings.Package = strings.TrimPrefix(entry, "package=")
+			if err := checkPackage(settings.Package); err != nil {
+				diag.Errorf(table.Name, "", "%v", err)
+				settings.Package = ""
+			}
+		} else {
+			diag.Warnf(table.Name, "", "ignored table setting %v", entry)
 		}
 	}
 	return settings
 }
 
-func dbmlTableToGormString(table core.Table) string {
+func dbmlTableToGormString(table *schema.Table, ctx *common.Context, file *goFile) string {
+	diag := ctx.Diagnostics
 	str := ""
-	settings := parseTableSettings(table)
+	settings := file.layout.settings[table]
 	if settings.Hidden {
 		return ""
 	}
-	str += fmt.Sprintf("type %v struct {\n", table.Name)
+	name := typeName(table)
+	str += fmt.Sprintf("type %v struct {\n", name)
 	if len(settings.Inheritances) > 0 {
 		for _, inheritance := range settings.Inheritances {
+			file.use(inheritance)
 			str += fmt.Sprintf("    %v\n", inheritance)
 		}
 	}
+	model := modelColumns(table, ctx)
+	if model != nil {
+		file.use("gorm.Model")
+		str += "    gorm.Model\n"
+	}
+	softDelete := model != nil
+	fields := map[string]bool{}
 	for _, column := range table.Columns {
-		if column.Settings.Note != "hidden" {
-			columnType := types[column.Type]
-			columnParams := parseColumnParameters(column)
-
-			if columnType == "" {
-				if column.Settings.Ref.Type != core.None {
-					if column.Settings.Ref.Type == core.ManyToOne {
-						str += fmt.Sprintf("    %v int\n", column.Name+"ID")
-						columnType = column.Type
-					} else {
-						panic(fmt.Sprintf("Relation type %v is not yet supported", column.Settings.Ref.Type))
+		fields[fieldName(column)] = true
+		if column.IsVirtual() && column.Ref != nil {
+			fields[foreignKeyField(column)] = true
+		}
+	}
+	// addField adds a field that is not declared by a column, it is skipped if the name is already used
+	addField := func(column *schema.Column, name string, goType string, tags []string) {
+		if fields[name] {
+			diag.Warnf(table.Name, column.Name, "field %v is not created because the name is already used", name)
+			return
+		}
+		fields[name] = true
+		file.use(goType)
+		str += fmt.Sprintf("    %v %v%v\n", name, goType,
+			structTag(gormTag(tags), fieldTags(ctx, gormColumnName(name), true, nil, nil)))
+	}
+	indexes := indexSettings(table, diag)
+	associations := associations(table, ctx)
+	matches := matchAssociations(table, associations)
+	for _, column := range table.Columns {
+		if column.Note != common.SHidden && !model[column] {
+			if _, err := noteTags(column); err != nil {
+				diag.Warnf(table.Name, column.Name, "%v", err)
+			}
+			field := fieldName(column)
+			columnType := ctx.ColumnType(types, column)
+			extra := append(columnTag(field, column), timestampSettings(column)...)
+			if isSoftDelete(column, columnType) && len(indexes[column]) == 0 {
+				extra = append(extra, "index")
+			}
+			if column.Enum != nil && !hasPrefix(columnSettings(column), "type:") {
+				extra = append(extra, enumType(column.Enum, ctx))
+			}
+			if column.Enum != nil && column.Default != "" && !hasPrefix(columnSettings(column), "default:") {
+				extra = append(extra, enumDefault(column, ctx))
+			}
+			paramsColumn := column
+			if isSoftDelete(column, columnType) {
+				nullable := *column // the deletion time of models that are not deleted is NULL
+				nullable.Null = true
+				paramsColumn = &nullable
+			}
+			columnParams := parseColumnParameters(paramsColumn, append(extra, indexes[column]...))
+
+			if a, ok := matches[column]; ok {
+				columnType = "[]" + file.typeRef(a.other)
+				if !a.list {
+					columnType = "*" + file.typeRef(a.other)
+				}
+				columnParams = gormTag(append(columnSettings(column), a.tags...))
+			} else if column.Ref != nil {
+				belongsToType := file.typeRef(column.Ref.To.Table)
+				if column.Ref.To.Table == table {
+					belongsToType = "*" + belongsToType // a struct can not contain itself
+				}
+				if column.IsVirtual() {
+					foreignKeyType := ctx.ColumnType(types, column.Ref.To)
+					if foreignKeyType == "" {
+						foreignKeyType = "int"
 					}
-				} else {
+					foreignKeyType = columnGoType(column, foreignKeyType, ctx)
+					file.use(foreignKeyType)
+					str += fmt.Sprintf("    %v %v%v\n", foreignKeyField(column), foreignKeyType,
+						structTag(parseColumnParameters(column, indexes[column]),
+							fieldTags(ctx, gormColumnName(foreignKeyField(column)), column.Null,
+								validateRules(column, foreignKeyType), nil)))
+					if !isHidden(column.Ref.To.Table) {
+						file.use(belongsToType)
+						str += fmt.Sprintf("    %v %v%v\n", field, belongsToType,
+							structTag(gormTag(belongsToTags(column)), fieldTags(ctx, jsonName(column), true, nil, column)))
+					}
+					continue
+				}
+				if columnType == "" {
+					diag.Warnf(table.Name, column.Name, "unknown column type %v is used as Go type", column.Type)
 					columnType = column.Type
 				}
+				columnType = columnGoType(column, columnType, ctx)
+				file.use(columnType)
+				str += fmt.Sprintf("    %v %v%v\n", field, columnType, structTag(columnParams,
+					fieldTags(ctx, jsonName(column), column.Null && !column.PK, validateRules(column, columnType), column)))
+				if !isHidden(column.Ref.To.Table) {
+					addField(column, belongsToName(column), belongsToType, belongsToTags(column))
+				}
+				continue
+			} else if columnType == "" {
+				if column.Enum == nil && !column.IsVirtual() {
+					diag.Warnf(table.Name, column.Name, "unknown column type %v is used as Go type", column.Type)
+				}
+				columnType = column.Type
+				if column.Enum != nil {
+					columnType = file.enumRef(column.Enum)
+				} else if column.IsVirtual() {
+					columnType = file.typeRef(column.Object)
+				}
+				if column.List {
+					columnType = "[]" + columnType
+				}
 			}
-			str += fmt.Sprintf("    %v %v%v\n", column.Name, columnType, columnParams)
+			tags := fieldTags(ctx, jsonName(column), true, nil, column)
+			if _, ok := matches[column]; !ok && !column.IsVirtual() {
+				if isSoftDelete(column, columnType) {
+					columnType = "gorm.DeletedAt" // can hold NULL
+					softDelete = true
+				} else {
+					columnType = columnGoType(column, columnType, ctx)
+				}
+				tags = fieldTags(ctx, jsonName(column), paramsColumn.Null && !column.PK,
+					validateRules(paramsColumn, columnType), column)
+			}
+			file.use(columnType)
+			str += fmt.Sprintf("    %v %v%v\n", field, columnType, structTag(columnParams, tags))
 		}
 	}
+	if settings.SoftDelete && !softDelete && !fields["DeletedAt"] {
+		fields["DeletedAt"] = true
+		file.use("gorm.DeletedAt")
+		str += "    " + softDeleteField + "\n"
+	}
+	for _, a := range associations {
+		if isMatched(matches, a) || !file.importsImplicitly(a) {
+			continue
+		}
+		name, goType := typeName(a.other), "*"+file.typeRef(a.other)
+		if a.list {
+			name, goType = schema.Plural(typeName(a.other)), "[]"+file.typeRef(a.other)
+		}
+		if fields[name] && a.relation.Type != schema.ManyToMany {
+			name = goName(belongsToName(a.relation.From)) + name // e.g. AuthorPosts and EditorPosts
+		}
+		addField(a.relation.To, name, goType, a.tags)
+	}
 	str += "}\n\n"
+	str += fmt.Sprintf("func (%v) TableName() string {\n    return %q\n}\n\n", name, tableName(table, settings, ctx))
+	if err := common.CheckGoDecls(str); err != nil {
+		diag.Errorf(table.Name, "", "generated Go code does not parse: %v", err)
+		return ""
+	}
 	return str
 }
 
-func parseColumnParameters(column core.Column) string {
-	var settings []string
+// gormTag returns the gorm tag of the settings (gorm:"...") or an empty string if there are none
+func gormTag(settings []string) string {
+	if len(settings) > 0 {
+		return fmt.Sprintf("gorm:\"%v\"", strings.Join(settings, ";"))
+	}
+	return ""
+}
 
-	match := gormRe.FindStringSubmatch(column.Settings.Note)
-	if len(match) == 2 {
-		for _, entry := range strings.Split(match[1], ";") {
+// columnSettings returns the gorm settings of the column note
+func columnSettings(column *schema.Column) []string {
+	var settings []string
+	for _, entry := range column.Settings.Only(schema.TargetGorm) {
+		if !isSpecialSetting(entry) {
 			settings = append(settings, strings.ToLower(entry))
 		}
 	}
+	return settings
+}
+
+// parseColumnParameters returns the gorm tag of the column. indexes are the settings of the indexes of the column.
+func parseColumnParameters(column *schema.Column, indexes []string) string {
+	settings := append(columnSettings(column), indexes...)
 
 	primarykey := "primarykey"
-	if column.Settings.PK && !slice.Contains(settings, primarykey) {
+	if column.PK && !slice.Contains(settings, primarykey) {
 		settings = append(settings, primarykey)
 	}
 	key := "autoincrement"
-	if column.Settings.Increment && !slice.Contains(settings, key) && !slice.Contains(settings, primarykey) {
+	if column.Increment && !slice.Contains(settings, key) && !slice.Contains(settings, primarykey) {
 		settings = append(settings, key) // Add auto-increment just if not primarykey
 	}
 	key = "unique"
-	if column.Settings.Unique && !slice.Contains(settings, key) && !slice.Contains(settings, primarykey) {
+	if column.Unique && !slice.Contains(settings, key) && !slice.Contains(settings, primarykey) {
 		settings = append(settings, key)
 	}
 	key = "not null"
-	if !column.Settings.Null && !slice.Contains(settings, key) && !slice.Contains(settings, primarykey) {
+	if !column.Null && !slice.Contains(settings, key) && !slice.Contains(settings, primarykey) {
 		settings = append(settings, key)
 	}
 	key = "default"
-	if column.Settings.Default != "" && !slice.Contains(settings, key) {
-		reDefault := regexp.MustCompile(`^gorm:"[^"]*?default:[^"]*?"$`)
-		if len(reDefault.FindString(column.Settings.Note)) == 0 {
-			settings = append(settings, "default:"+column.Settings.Default)
-		}
+	if column.Default != "" && !hasPrefix(settings, key+":") {
+		settings = append(settings, "default:"+column.Default)
 	}
-	if len(settings) > 0 {
-		return fmt.Sprintf(" `gorm:\"%v\"`", strings.Join(settings, ";"))
+	return gormTag(settings)
+}
+
+// options of the generator
+const (
+	OPackage  = "package"
+	OEnum     = "enum"
+	OEnumType = "enum_type"
+	// OFiles selects whether the models are written to a single file per package or to a file per table
+	OFiles = "files"
+	// OImportPath is the Go import path of the output path, needed if the models are split into packages
+	OImportPath = "import_path"
+	// ONull selects the Go types of nullable columns
+	ONull = "null"
+	// OTags are the tag families that are added besides the gorm tag
+	OTags = "tags"
+	// OModel embeds gorm.Model in the models of tables with the columns id, created_at, updated_at and deleted_at
+	OModel = "model"
+)
+
+const defaultPackage = "model"
+
+// Generator creates Gorm models in a file per package or per table.
+type Generator struct{}
+
+func init() {
+	common.Register(Generator{})
+}
+
+func (Generator) Name() string {
+	return "gorm"
+}
+
+func (Generator) Description() string {
+	return "Creates Gorm models"
+}
+
+func (Generator) Options() []common.Option {
+	return []common.Option{
+		{Name: OPackage, Description: "Name of the Go package of the models", Default: defaultPackage},
+		{Name: OEnum, Description: "Go type of the enums: int or string", Default: EnumInt},
+		{Name: OEnumType, Description: "Database type of enum columns: portable (smallint or varchar) or postgres " +
+			"(native enum type named after the enum)", Default: EnumTypePortable},
+		{Name: OFiles, Description: "single (model.gen.go per package) or table (a file per table and enums.gen.go)",
+			Default: FilesSingle},
+		{Name: OImportPath, Description: "Go import path of the output directory, needed for models in other " +
+			"packages (gorm:`package=billing`)"},
+		{Name: ONull, Description: "Go types of nullable columns: pointer (*string), sql (sql.NullString) or " +
+			"datatypes (datatypes.Null[string])", Default: NullPointer},
+		{Name: OTags, Description: "Comma separated tags to add besides the gorm tag: json and validate"},
+		{Name: OModel, Description: "true to embed gorm.Model in models with the columns id, created_at, updated_at " +
+			"and deleted_at", Default: "false"},
 	}
-	return ""
 }
 
-func CreateGormFiles(dbml *core.DBML, outputPath string) {
-	gormString := dbmlToGormString(dbml, outputPath)
-	common.WriteToFile(gormString, filepath.Join(outputPath, "model.gen.go"))
+func (Generator) Generate(ctx *common.Context) ([]common.File, error) {
+	checkEnumOptions(ctx)
+	checkNullOption(ctx)
+	checkTagsOption(ctx)
+	return gormFiles(ctx), nil
+}
+
+// CreateGormFiles creates the Gorm models of dbml in outputPath.
+//
+// Deprecated: use the generator registered as "gorm" (common.Lookup), it returns the files and reports the
+// warnings of the schema.
+func CreateGormFiles(dbml *core.DBML, outputPath string) error {
+	return common.CreateFiles("gorm", dbml, outputPath)
 }
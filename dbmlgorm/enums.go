@@ -0,0 +1,212 @@
+package dbmlgorm
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+)
+
+// kinds of enums (option OEnum)
+const (
+	EnumInt    = "int"
+	EnumString = "string"
+)
+
+// database types of enum columns (option OEnumType)
+const (
+	// EnumTypePortable stores enums as smallint or varchar
+	EnumTypePortable = "portable"
+	// EnumTypePostgres stores enums as native Postgres enum types named after the enum, e.g. "order_status"
+	EnumTypePostgres = "postgres"
+)
+
+// enumMethods are the methods of all enums: %[1]v is the name of the type, %[2]v the list of its constants
+const enumMethods = `// Values returns all values of %[1]v.
+func (%[1]v) Values() []%[1]v {
+	return []%[1]v{%[2]v}
+}
+
+// MarshalJSON encodes the enum as its name.
+func (e %[1]v) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+// UnmarshalJSON decodes the name of a value of the enum.
+func (e *%[1]v) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	value, err := Parse%[1]v(name)
+	if err != nil {
+		return err
+	}
+	*e = value
+	return nil
+}
+
+// Parse%[1]v returns the value of %[1]v with the given name.
+func Parse%[1]v(name string) (%[1]v, error) {
+	var e %[1]v
+	for _, value := range e.Values() {
+		if value.String() == name {
+			return value, nil
+		}
+	}
+	return e, fmt.Errorf("invalid %[1]v %%q", name)
+}
+
+`
+
+const stringEnumMethods = `// String returns the name of the value.
+func (e %[1]v) String() string {
+	return string(e)
+}
+
+// Scan implements the sql.Scanner interface.
+func (e *%[1]v) Scan(src interface{}) error {
+	var name string
+	switch v := src.(type) {
+	case string:
+		name = v
+	case []byte:
+		name = string(v)
+	default:
+		return fmt.Errorf("can not scan %%T into %[1]v", src)
+	}
+	value, err := Parse%[1]v(name)
+	if err != nil {
+		return err
+	}
+	*e = value
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (e %[1]v) Value() (driver.Value, error) {
+	return string(e), nil
+}
+
+`
+
+const intEnumMethods = `// String returns the name of the value.
+func (e %[1]v) String() string {
+	switch e {
+%[2]v	}
+	return fmt.Sprintf("%[1]v(%%d)", uint(e))
+}
+
+// Scan implements the sql.Scanner interface.
+func (e *%[1]v) Scan(src interface{}) error {
+	v, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("can not scan %%T into %[1]v", src)
+	}
+	if v < 0 || v >= int64(len(e.Values())) {
+		return fmt.Errorf("invalid %[1]v %%d", v)
+	}
+	*e = %[1]v(v)
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (e %[1]v) Value() (driver.Value, error) {
+	return int64(e), nil
+}
+
+`
+
+// enumName returns the name of the Go type of an enum
+func enumName(enum *schema.Enum) string {
+	return goName(enum.Name)
+}
+
+// enumConstant returns the name of the constant of an enum value, e.g. "OrderStatusShipped"
+func enumConstant(enum *schema.Enum, value schema.EnumValue) string {
+	return enumName(enum) + goName(value.Name)
+}
+
+// checkEnumOptions reports unknown values of the enum options
+func checkEnumOptions(ctx *common.Context) {
+	kind, dbType := ctx.Options.Get(OEnum, EnumInt), ctx.Options.Get(OEnumType, EnumTypePortable)
+	if kind != EnumInt && kind != EnumString {
+		ctx.Diagnostics.Errorf("", "", "unknown value %v of option %v (expected %v or %v)", kind, OEnum, EnumInt,
+			EnumString)
+	}
+	if dbType != EnumTypePortable && dbType != EnumTypePostgres {
+		ctx.Diagnostics.Errorf("", "", "unknown value %v of option %v (expected %v or %v)", dbType, OEnumType,
+			EnumTypePortable, EnumTypePostgres)
+	}
+	if kind == EnumInt && dbType == EnumTypePostgres {
+		ctx.Diagnostics.Errorf("", "", "option %v=%v needs %v=%v, Postgres enums store the names of the values",
+			OEnumType, EnumTypePostgres, OEnum, EnumString)
+	}
+}
+
+func dbmlEnumToGormString(enum *schema.Enum, ctx *common.Context) string {
+	name := enumName(enum)
+	kind := ctx.Options.Get(OEnum, EnumInt)
+	str := ""
+	if kind == EnumString {
+		str += fmt.Sprintf("type %v string\n\nconst (\n", name)
+	} else {
+		str += fmt.Sprintf("type %v uint\n\nconst (\n", name)
+	}
+	constants := ""
+	cases := ""
+	for i, value := range enum.Values {
+		constant := enumConstant(enum, value)
+		str += fmt.Sprintf("    %v", constant)
+		if kind == EnumString {
+			str += fmt.Sprintf(" %v = %q", name, value.Name)
+		} else if i == 0 {
+			str += fmt.Sprintf(" %v = iota", name)
+		}
+		if value.Note != "" {
+			str += fmt.Sprintf("\t// %v", value.Note)
+		}
+		str += "\n"
+		if i > 0 {
+			constants += ", "
+		}
+		constants += constant
+		cases += fmt.Sprintf("\tcase %v:\n\t\treturn %q\n", constant, value.Name)
+	}
+	str += ")\n\n"
+	str += fmt.Sprintf(enumMethods, name, constants)
+	if kind == EnumString {
+		str += fmt.Sprintf(stringEnumMethods, name)
+	} else {
+		str += fmt.Sprintf(intEnumMethods, name, cases)
+	}
+	return str
+}
+
+// enumType returns the gorm type setting of an enum column
+func enumType(enum *schema.Enum, ctx *common.Context) string {
+	if ctx.Options.Get(OEnumType, EnumTypePortable) == EnumTypePostgres {
+		return "type:" + schema.SnakeCase(enum.Name)
+	}
+	if ctx.Options.Get(OEnum, EnumInt) == EnumString {
+		length := 1
+		for _, value := range enum.Values {
+			if len(value.Name) > length {
+				length = len(value.Name)
+			}
+		}
+		return fmt.Sprintf("type:varchar(%v)", length)
+	}
+	return "type:smallint"
+}
+
+// enumDefault returns the gorm default setting of an enum column, int enums store the position of the value
+func enumDefault(column *schema.Column, ctx *common.Context) string {
+	if ctx.Options.Get(OEnum, EnumInt) == EnumInt {
+		for i, value := range column.Enum.Values {
+			if value.Name == column.Default {
+				return fmt.Sprintf("default:%v", i)
+			}
+		}
+	}
+	return "default:" + column.Default
+}
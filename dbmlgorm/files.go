@@ -0,0 +1,282 @@
+package dbmlgorm
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// layouts of the generated files (option OFiles)
+const (
+	FilesSingle = "single"
+	FilesTable  = "table"
+)
+
+// layout assigns the models to Go packages. Packages are directories relative to the output path, "" is the package
+// of the output path itself.
+type layout struct {
+	ctx      *common.Context
+	settings map[*schema.Table]TableSettings
+	// imports holds the packages imported by every package
+	imports map[string]map[string]bool
+	// missingImportPath is set if a package is imported but the option OImportPath is not set
+	missingImportPath bool
+	// templates are the templates of the packages
+	templates map[string]goTemplate
+}
+
+func newLayout(ctx *common.Context) *layout {
+	l := &layout{ctx: ctx, settings: map[*schema.Table]TableSettings{}, imports: map[string]map[string]bool{},
+		templates: map[string]goTemplate{}}
+	for _, table := range ctx.Schema.Tables {
+		l.settings[table] = parseTableSettings(table, ctx.Diagnostics)
+	}
+	for _, dir := range l.dirs() {
+		filePath := filepath.Join(ctx.OutputPath, filepath.FromSlash(dir), "model.go.template")
+		l.templates[dir] = parseTemplate(getTemplate(filePath, ctx))
+		if name := ctx.Options.Get(OPackage, ""); dir == "" && name != "" && l.templates[dir].pkg != "" &&
+			l.templates[dir].pkg != name {
+			ctx.Diagnostics.Warnf("", "", "the package %v of %v is used instead of the option %v=%v",
+				l.templates[dir].pkg, filePath, OPackage, name)
+		}
+	}
+	return l
+}
+
+// goTemplate is the content of model.go.template. A template can be a Go file of its own that starts with a package
+// clause and imports, the generated file then keeps its package name and imports.
+type goTemplate struct {
+	// preamble are the comments before the package clause, pkg is the package name or empty if there is no clause
+	preamble, pkg string
+	imports       map[string]string
+	// code is added after the imports
+	code string
+}
+
+// parseTemplate splits a template into its package clause, imports and code
+func parseTemplate(template string) goTemplate {
+	file, err := parser.ParseFile(token.NewFileSet(), "", template, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return goTemplate{code: template} // just code that is added after the imports
+	}
+	t := goTemplate{preamble: template[:file.Package-1], pkg: file.Name.Name, imports: map[string]string{}}
+	end := file.Name.End()
+	for _, decl := range file.Decls {
+		end = decl.End()
+	}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		t.imports[importPath] = ""
+		if spec.Name != nil {
+			t.imports[importPath] = spec.Name.Name
+		}
+	}
+	t.code = strings.TrimLeft(template[end-1:], ";\n")
+	if t.code != "" && !strings.HasSuffix(t.code, "\n\n") {
+		t.code = strings.TrimRight(t.code, "\n") + "\n\n"
+	}
+	return t
+}
+
+// dirs returns the packages of all models, the package of the output path is always part of it
+func (l *layout) dirs() []string {
+	found := map[string]bool{"": true}
+	for _, settings := range l.settings {
+		if !settings.Hidden {
+			found[settings.Package] = true
+		}
+	}
+	var dirs []string
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// packageName returns the name of the Go package of a directory
+func (l *layout) packageName(dir string) string {
+	if pkg := l.templates[dir].pkg; pkg != "" {
+		return pkg
+	}
+	if dir == "" {
+		return l.ctx.Options.Get(OPackage, defaultPackage)
+	}
+	return path.Base(dir)
+}
+
+// importPath returns the import path of a directory
+func (l *layout) importPath(dir string) string {
+	importPath := l.ctx.Options.Get(OImportPath, "")
+	if importPath == "" {
+		l.missingImportPath = true
+	}
+	if dir == "" {
+		return importPath
+	}
+	return importPath + "/" + dir
+}
+
+// newFile returns an empty Go file of the package dir
+func (l *layout) newFile(dir string) *goFile {
+	return &goFile{imports: map[string]string{}, dir: dir, layout: l}
+}
+
+// typeRef returns the name of the model of a table as it is used in the file, e.g. "billing.Invoice" if the table
+// belongs to another package
+func (f *goFile) typeRef(table *schema.Table) string {
+	return f.qualify(f.layout.settings[table].Package, typeName(table))
+}
+
+// enumRef returns the name of an enum as it is used in the file, enums belong to the package of the output path
+func (f *goFile) enumRef(enum *schema.Enum) string {
+	return f.qualify("", enumName(enum))
+}
+
+// qualify returns the name declared in the package dir as it is used in the file and imports the package if needed
+func (f *goFile) qualify(dir string, name string) string {
+	if dir == f.dir {
+		return name
+	}
+	if f.layout.imports[f.dir] == nil {
+		f.layout.imports[f.dir] = map[string]bool{}
+	}
+	f.layout.imports[f.dir][dir] = true
+	f.imports[f.layout.importPath(dir)] = ""
+	return f.layout.packageName(dir) + "." + name
+}
+
+// importsImplicitly reports whether an association that is not declared by a column is added to the file. To avoid
+// import cycles only the table with the foreign key (or the left table of a many-to-many relation) refers to a table
+// of another package unless a column declares the association.
+func (f *goFile) importsImplicitly(a *association) bool {
+	if f.layout.settings[a.other].Package == f.dir {
+		return true
+	}
+	return a.relation.Type == schema.ManyToMany && a.relation.From.Table != a.other
+}
+
+// source returns the Go source of the file with the declarations decls
+func (f *goFile) source(decls string) string {
+	return header + f.preamble + fmt.Sprintf("package %v\n\n", f.layout.packageName(f.dir)) +
+		common.ImportBlock(f.imports) + decls
+}
+
+// importCycle returns the packages of an import cycle or nil if there is none, Go does not allow them
+func (l *layout) importCycle() []string {
+	visited := map[string]bool{}
+	var stack []string
+	var visit func(dir string) []string
+	visit = func(dir string) []string {
+		for i, d := range stack {
+			if d == dir {
+				return append(append([]string{}, stack[i:]...), dir)
+			}
+		}
+		if visited[dir] {
+			return nil
+		}
+		visited[dir] = true
+		stack = append(stack, dir)
+		var imported []string
+		for d := range l.imports[dir] {
+			imported = append(imported, d)
+		}
+		sort.Strings(imported)
+		for _, d := range imported {
+			if cycle := visit(d); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		return nil
+	}
+	for _, dir := range l.dirs() {
+		if cycle := visit(dir); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// checkPackage returns an error if the package setting of a table is no relative directory with a valid package name
+func checkPackage(dir string) error {
+	if dir != path.Clean(dir) || path.IsAbs(dir) || dir == "." || strings.HasPrefix(dir, "..") {
+		return fmt.Errorf("package %v is no clean relative directory like billing or internal/billing", dir)
+	}
+	if name := path.Base(dir); !token.IsIdentifier(name) {
+		return fmt.Errorf("package %v is no valid Go package name", name)
+	}
+	return nil
+}
+
+// gormFiles returns the Go files of the models, their paths are relative to the output path
+func gormFiles(ctx *common.Context) []common.File {
+	l := newLayout(ctx)
+	filesOption := ctx.Options.Get(OFiles, FilesSingle)
+	if filesOption != FilesSingle && filesOption != FilesTable {
+		ctx.Diagnostics.Errorf("", "", "unknown value %v of option %v (expected %v or %v)", filesOption, OFiles,
+			FilesSingle, FilesTable)
+	}
+	var files []common.File
+	add := func(filePath string, file *goFile, decls string) {
+		formatted, err := common.FormatGo(file.source(decls), packages)
+		if err != nil {
+			ctx.Diagnostics.Errorf("", "", "generated file %v does not parse: %v", filePath, err)
+		}
+		files = append(files, common.File{Path: filePath, Content: formatted})
+	}
+	for _, dir := range l.dirs() {
+		shared := l.newFile(dir)
+		// the template is added to the file of the enums, its imports and comments are kept
+		template := l.templates[dir]
+		shared.preamble = template.preamble
+		for importPath, name := range template.imports {
+			shared.imports[importPath] = name
+		}
+		str := template.code
+		if dir == "" {
+			for _, enum := range ctx.Schema.Enums {
+				str += dbmlEnumToGormString(enum, ctx)
+			}
+		}
+		for _, table := range ctx.Schema.Tables {
+			if settings := l.settings[table]; settings.Hidden || settings.Package != dir {
+				continue
+			}
+			if filesOption == FilesTable {
+				file := l.newFile(dir)
+				add(path.Join(dir, table.SnakeName()+".gen.go"), file, dbmlTableToGormString(table, ctx, file))
+			} else {
+				str += dbmlTableToGormString(table, ctx, shared)
+			}
+		}
+		if filesOption != FilesTable {
+			add(path.Join(dir, "model.gen.go"), shared, str)
+		} else if str != "" {
+			add(path.Join(dir, "enums.gen.go"), shared, str)
+		}
+	}
+	if l.missingImportPath {
+		ctx.Diagnostics.Errorf("", "", "option %v is needed to import the packages of other models", OImportPath)
+	}
+	if cycle := l.importCycle(); cycle != nil {
+		var names []string
+		for _, dir := range cycle {
+			if dir == "" {
+				dir = "."
+			}
+			names = append(names, dir)
+		}
+		ctx.Diagnostics.Errorf("", "", "the packages of the models import each other: %v", strings.Join(names, " -> "))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
@@ -0,0 +1,84 @@
+package dbmlgorm
+
+import (
+	"flag"
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// golden returns the diagnostics and the files of a run in the format of the golden files
+func golden(files []common.File, diag *common.Diagnostics) string {
+	var b strings.Builder
+	for _, d := range diag.List() {
+		fmt.Fprintf(&b, "%v: %v\n", d.Severity, d.Error)
+	}
+	for _, file := range files {
+		fmt.Fprintf(&b, "-- %v --\n%v", file.Path, file.Content)
+	}
+	return b.String()
+}
+
+// TestGolden generates the models of testdata/<dbml>.dbml and compares them with testdata/<name>.golden, go test
+// -update rewrites the golden files
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbml    string
+		options common.Options
+	}{
+		{"relations", "relations", common.Options{OTags: "json"}},
+		{"indexes", "indexes", nil},
+		{"enums", "enums", common.Options{OTags: "json"}},
+		{"enums_string_postgres", "enums", common.Options{OEnum: EnumString, OEnumType: EnumTypePostgres}},
+		{"packages", "packages", common.Options{OImportPath: "example.com/app/model"}},
+		{"packages_table", "packages", common.Options{OImportPath: "example.com/app/model", OFiles: FilesTable}},
+		{"null_pointer", "null", common.Options{OTags: "json,validate"}},
+		{"null_sql", "null", common.Options{ONull: NullSQL}},
+		{"null_datatypes", "null", common.Options{ONull: NullDatatypes}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := ioutil.ReadFile(filepath.Join("testdata", test.dbml+".dbml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			document, err := common.ParseDocument(test.dbml+".dbml", src)
+			if err != nil {
+				t.Fatalf("ParseDocument() error = %v", err)
+			}
+			diag := &common.Diagnostics{}
+			s := schema.Build(document.DBML, diag)
+			s.ApplyExtensions(document.Extensions)
+			ctx := common.NewContext(s, filepath.Join("testdata", "out"), test.options)
+			ctx.Diagnostics = diag
+			files, err := Generator{}.Generate(ctx)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			diag.Locate(document.SourceMap)
+			got := golden(files, diag)
+
+			path := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("generated files differ from %v (go test -update rewrites it):\n%v", path,
+					common.UnifiedDiff(path, "generated", string(want), got))
+			}
+		})
+	}
+}
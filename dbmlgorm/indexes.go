@@ -0,0 +1,92 @@
+package dbmlgorm
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"regexp"
+	"strings"
+)
+
+// indexName returns the name of the index, composite indexes without name are named after their columns
+func indexName(index *schema.Index) string {
+	if index.Name != "" || len(index.Columns) < 2 {
+		return index.Name
+	}
+	name := "idx_" + index.Table.SnakeName()
+	for _, column := range index.Columns {
+		name += "_" + column.SnakeName()
+	}
+	return name
+}
+
+// expressionColumn returns the column an expression index is added to: the first column that is used in the
+// expression
+func expressionColumn(table *schema.Table, expression string) *schema.Column {
+	for _, column := range table.Columns {
+		if !column.IsVirtual() && regexp.MustCompile(`\b`+regexp.QuoteMeta(column.Name)+`\b`).MatchString(expression) {
+			return column
+		}
+	}
+	return nil
+}
+
+// indexSettings returns the gorm settings of the indexes of the table by column, e.g. "index:idx_name,priority:2".
+// Indexes that gorm can not express are reported as warnings and skipped.
+func indexSettings(table *schema.Table, diag *common.Diagnostics) map[*schema.Column][]string {
+	settings := map[*schema.Column][]string{}
+	for _, index := range table.Indexes {
+		if index.PK {
+			for _, column := range index.Columns {
+				settings[column] = append(settings[column], "primarykey")
+			}
+			continue
+		}
+		kind := "index"
+		if index.Unique {
+			kind = "uniqueIndex"
+		}
+		var options []string
+		if index.Type != "" {
+			options = append(options, "type:"+index.Type)
+		}
+		options = append(options, index.Settings.Only(schema.TargetGorm)...)
+		unsupported := ""
+		for _, option := range append(append([]string(nil), options...), index.Expressions...) {
+			if strings.ContainsAny(option, ",;") {
+				unsupported = fmt.Sprintf("%v contains a comma or semicolon", option)
+			}
+		}
+
+		columns := index.Columns
+		if len(index.Expressions) > 0 {
+			if len(index.Columns) > 0 || len(index.Expressions) > 1 {
+				unsupported = "it has more than one expression or mixes expressions with columns"
+			} else if column := expressionColumn(table, index.Expressions[0]); column == nil {
+				unsupported = "its expression does not use a column of the table"
+			} else {
+				columns = []*schema.Column{column}
+				options = append(options, "expression:"+index.Expressions[0])
+			}
+		}
+		if unsupported != "" {
+			diag.Warnf(table.Name, "", "index %v is skipped, gorm can not express it: %v", index.Describe(),
+				unsupported)
+			continue
+		}
+
+		name := indexName(index)
+		for i, column := range columns {
+			columnOptions := options
+			if len(columns) > 1 {
+				columnOptions = append([]string{fmt.Sprintf("priority:%v", i+1)}, options...)
+			}
+			setting := kind
+			if name != "" || len(columnOptions) > 0 {
+				setting += ":" + strings.Join(append([]string{name}, columnOptions...), ",")
+			}
+			settings[column] = append(settings[column], setting)
+		}
+	}
+	return settings
+}
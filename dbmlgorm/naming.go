@@ -0,0 +1,99 @@
+package dbmlgorm
+
+import (
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"strings"
+)
+
+// commonInitialisms are the initialisms gorm treats as one word when it converts a field name into a column name
+var commonInitialisms = []string{"API", "ASCII", "CPU", "CSS", "DNS", "EOF", "GUID", "HTML", "HTTP", "HTTPS", "ID",
+	"IP", "JSON", "LHS", "QPS", "RAM", "RHS", "RPC", "SLA", "SMTP", "SSH", "TLS", "TTL", "UID", "UI", "UUID", "URI",
+	"URL", "UTF8", "VM", "XML", "XSRF", "XSS"}
+
+var initialismReplacer *strings.Replacer
+
+func init() {
+	var pairs []string
+	for _, initialism := range commonInitialisms {
+		pairs = append(pairs, initialism, initialism[:1]+strings.ToLower(initialism[1:]))
+	}
+	initialismReplacer = strings.NewReplacer(pairs...)
+}
+
+// typeName returns the name of the struct of a table, e.g. "BlogPost" for "blog_post"
+func typeName(table *schema.Table) string {
+	return goName(table.Name)
+}
+
+// fieldName returns the name of the struct field of a column, e.g. "AuthorID" for "author_id" and "ID" for "id"
+func fieldName(column *schema.Column) string {
+	name := goName(column.Name)
+	if strings.HasSuffix(name, "Id") {
+		name = strings.TrimSuffix(name, "Id") + "ID"
+	}
+	return name
+}
+
+// tableName returns the name of the table in the database, the gorm table setting takes precedence over the naming
+func tableName(table *schema.Table, settings TableSettings, ctx *common.Context) string {
+	if settings.TableName != "" {
+		return settings.TableName
+	}
+	return ctx.Naming.TableName(table)
+}
+
+// columnName returns the name of the column in the database, its dbml name
+func columnName(column *schema.Column) string {
+	return column.Name
+}
+
+// jsonName returns the name of the field of a column in JSON, the snake case name of the column
+func jsonName(column *schema.Column) string {
+	return column.SnakeName()
+}
+
+// columnTag returns the column tag of a field if gorm would derive another column name than the dbml name from the
+// field name, e.g. "column:firstName" for the field FirstName
+func columnTag(field string, column *schema.Column) []string {
+	if name := columnName(column); gormColumnName(field) != name {
+		return []string{"column:" + name}
+	}
+	return nil
+}
+
+// gormColumnName returns the column name gorm derives from a field name (a port of toDBName of gorm's
+// NamingStrategy), e.g. "user_id" for "UserID" and "http_server" for "HTTPServer"
+func gormColumnName(field string) string {
+	if field == "" {
+		return ""
+	}
+	value := initialismReplacer.Replace(field)
+	isUpper := func(c byte) bool { return c >= 'A' && c <= 'Z' }
+	var b strings.Builder
+	lastCase, curCase := false, isUpper(value[0])
+	for i := 0; i < len(value)-1; i++ {
+		nextCase := isUpper(value[i+1])
+		nextNumber := value[i+1] >= '0' && value[i+1] <= '9'
+		if curCase {
+			if !lastCase || !(nextCase || nextNumber) {
+				if i > 0 && value[i-1] != '_' && value[i+1] != '_' {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteByte(value[i] + 32)
+		} else {
+			b.WriteByte(value[i])
+		}
+		lastCase, curCase = curCase, nextCase
+	}
+	if curCase {
+		if !lastCase && len(value) > 1 {
+			b.WriteByte('_')
+		}
+		b.WriteByte(value[len(value)-1] + 32)
+	} else {
+		b.WriteByte(value[len(value)-1])
+	}
+	return b.String()
+}
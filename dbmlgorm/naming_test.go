@@ -0,0 +1,37 @@
+package dbmlgorm
+
+import "testing"
+
+// TestGormColumnName compares gormColumnName with the column names of gorm's default naming strategy
+func TestGormColumnName(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"", ""},
+		{"A", "a"},
+		{"Name", "name"},
+		{"ID", "id"},
+		{"UUID", "uuid"},
+		{"UserID", "user_id"},
+		{"FirstName", "first_name"},
+		{"CreatedAt", "created_at"},
+		{"APIKey", "api_key"},
+		{"HTTPServer", "http_server"},
+		{"IPAddress", "ip_address"},
+		{"JSONData", "json_data"},
+		{"URLs", "urls"},
+		{"ABC", "abc"},
+		{"Field1", "field1"},
+		{"Address2Line", "address2_line"},
+		{"OAuth2Token", "o_auth2_token"},
+		{"User_Name", "user_name"},
+	}
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			if got := gormColumnName(test.field); got != test.want {
+				t.Errorf("gormColumnName(%q) = %q, want %q", test.field, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package dbmlgorm
+
+import (
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"strings"
+)
+
+// strategies for nullable columns (option ONull)
+const (
+	// NullPointer uses pointers, e.g. *string
+	NullPointer = "pointer"
+	// NullSQL uses the types of database/sql like sql.NullString and pointers for types without sql type
+	NullSQL = "sql"
+	// NullDatatypes uses datatypes.Null[T] of gorm.io/datatypes
+	NullDatatypes = "datatypes"
+)
+
+// sqlNullTypes are the nullable types of database/sql (and decimal) of Go types
+var sqlNullTypes = map[string]string{
+	"string":          "sql.NullString",
+	"int":             "sql.NullInt64",
+	"int64":           "sql.NullInt64",
+	"int32":           "sql.NullInt32",
+	"int16":           "sql.NullInt16",
+	"byte":            "sql.NullByte",
+	"uint8":           "sql.NullByte",
+	"float64":         "sql.NullFloat64",
+	"bool":            "sql.NullBool",
+	"time.Time":       "sql.NullTime",
+	"decimal.Decimal": "decimal.NullDecimal",
+}
+
+// isNullable reports whether a Go type can hold NULL already (pointers, slices, maps and types like sql.NullString)
+func isNullable(goType string) bool {
+	if strings.HasPrefix(goType, "*") || strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[") {
+		return true
+	}
+	name := goType
+	if i := strings.Index(name, "["); i > 0 {
+		name = name[:i] // datatypes.Null[string]
+	}
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.HasPrefix(name, "Null")
+}
+
+// nullType returns the Go type of a nullable column of type goType according to the strategy
+func nullType(goType string, strategy string) string {
+	if goType == "" || isNullable(goType) {
+		return goType
+	}
+	switch strategy {
+	case NullSQL:
+		if sqlType, ok := sqlNullTypes[goType]; ok {
+			return sqlType
+		}
+	case NullDatatypes:
+		return "datatypes.Null[" + goType + "]"
+	}
+	return "*" + goType
+}
+
+// columnGoType returns the Go type of a column that holds the type goType, nullable columns (except primary keys) get
+// a type that can hold NULL
+func columnGoType(column *schema.Column, goType string, ctx *common.Context) string {
+	if !column.Null || column.PK {
+		return goType
+	}
+	return nullType(goType, ctx.Options.Get(ONull, NullPointer))
+}
+
+// checkNullOption reports an unknown value of the option ONull
+func checkNullOption(ctx *common.Context) {
+	if strategy := ctx.Options.Get(ONull, NullPointer); strategy != NullPointer && strategy != NullSQL &&
+		strategy != NullDatatypes {
+		ctx.Diagnostics.Errorf("", "", "unknown value %v of option %v (expected %v, %v or %v)", strategy, ONull,
+			NullPointer, NullSQL, NullDatatypes)
+	}
+}
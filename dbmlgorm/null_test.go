@@ -0,0 +1,34 @@
+package dbmlgorm
+
+import "testing"
+
+func TestNullType(t *testing.T) {
+	tests := []struct {
+		goType   string
+		strategy string
+		want     string
+	}{
+		{"string", NullPointer, "*string"},
+		{"string", NullSQL, "sql.NullString"},
+		{"string", NullDatatypes, "datatypes.Null[string]"},
+		{"int", NullSQL, "sql.NullInt64"},
+		{"time.Time", NullSQL, "sql.NullTime"},
+		{"decimal.Decimal", NullSQL, "decimal.NullDecimal"},
+		{"uint", NullSQL, "*uint"},
+		{"OrderStatus", NullSQL, "*OrderStatus"},
+		{"*int", NullSQL, "*int"},
+		{"[]byte", NullPointer, "[]byte"},
+		{"map[string]string", NullDatatypes, "map[string]string"},
+		{"sql.NullString", NullPointer, "sql.NullString"},
+		{"datatypes.Null[int]", NullSQL, "datatypes.Null[int]"},
+		{"NullTime", NullDatatypes, "NullTime"},
+		{"", NullPointer, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.goType+"/"+test.strategy, func(t *testing.T) {
+			if got := nullType(test.goType, test.strategy); got != test.want {
+				t.Errorf("nullType(%q, %q) = %q, want %q", test.goType, test.strategy, got, test.want)
+			}
+		})
+	}
+}
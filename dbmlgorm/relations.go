@@ -0,0 +1,162 @@
+package dbmlgorm
+
+import (
+	"github.com/duythinht/dbml-go/core"
+	"github.com/gobeam/stringy"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"github.com/stretchr/stew/slice"
+	"strings"
+)
+
+// association is a field of a model that holds the models of another table
+type association struct {
+	relation *schema.Relation
+	// other is the table of the associated models
+	other *schema.Table
+	list  bool
+	tags  []string
+}
+
+// isHidden reports whether no model is created for the table
+func isHidden(table *schema.Table) bool {
+	return slice.Contains(table.Settings.Only(schema.TargetGorm), common.SHidden)
+}
+
+// goName returns the exported Go name of a dbml name, e.g. "author" -> "Author"
+func goName(name string) string {
+	return stringy.New(name).CamelCase()
+}
+
+// foreignKeyField returns the name of the field that holds the foreign key of the column. For "Author User" it is
+// "AuthorID".
+func foreignKeyField(column *schema.Column) string {
+	if column.IsVirtual() {
+		return fieldName(column) + "ID"
+	}
+	return fieldName(column)
+}
+
+// belongsToName returns the name of the belongs-to field of a foreign key column, e.g. "Author" for "author_id"
+func belongsToName(column *schema.Column) string {
+	if column.IsVirtual() {
+		return fieldName(column)
+	}
+	name := column.Name
+	for _, suffix := range []string{"_id", "Id", "ID"} {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	if name == column.Name {
+		return typeName(column.Ref.To.Table)
+	}
+	return goName(name)
+}
+
+// constraint returns the constraint tag of the referential actions or an empty string if there are none
+func constraint(relation *schema.Relation) string {
+	var actions []string
+	if relation.OnUpdate != "" {
+		actions = append(actions, "OnUpdate:"+strings.ToUpper(relation.OnUpdate))
+	}
+	if relation.OnDelete != "" {
+		actions = append(actions, "OnDelete:"+strings.ToUpper(relation.OnDelete))
+	}
+	if len(actions) == 0 {
+		return ""
+	}
+	return "constraint:" + strings.Join(actions, ",")
+}
+
+// belongsToTags returns the tags of the belongs-to field of a foreign key column
+func belongsToTags(column *schema.Column) []string {
+	tags := []string{"foreignKey:" + foreignKeyField(column), "references:" + fieldName(column.Ref.To)}
+	if c := constraint(column.Ref); c != "" {
+		tags = append(tags, c)
+	}
+	return tags
+}
+
+// joinTable returns the name of the join table of a many-to-many relation, e.g. "post_tags" for Post <> Tag
+func joinTable(relation *schema.Relation) string {
+	if relation.Name != "" {
+		return relation.Name
+	}
+	return relation.From.Table.SnakeName() + "_" + schema.Plural(relation.To.Table.SnakeName())
+}
+
+// associations returns the has-one, has-many and many-to-many associations of the table
+func associations(table *schema.Table, ctx *common.Context) []*association {
+	var list []*association
+	for _, relation := range ctx.Schema.RelationsTo(table) {
+		switch relation.Type {
+		case core.ManyToOne, core.OneToOne:
+			if isHidden(relation.From.Table) {
+				continue
+			}
+			list = append(list, &association{
+				relation: relation,
+				other:    relation.From.Table,
+				list:     relation.Type == core.ManyToOne,
+				tags:     []string{"foreignKey:" + foreignKeyField(relation.From), "references:" + fieldName(relation.To)},
+			})
+		case schema.ManyToMany:
+			own, other := relation.From, relation.To
+			if relation.From.Table != table {
+				own, other = relation.To, relation.From
+			}
+			if isHidden(other.Table) {
+				continue
+			}
+			tags := []string{"many2many:" + joinTable(relation)}
+			if !own.PK && !own.IsVirtual() {
+				tags = append(tags, "foreignKey:"+fieldName(own))
+			}
+			if !other.PK && !other.IsVirtual() {
+				tags = append(tags, "references:"+fieldName(other))
+			}
+			list = append(list, &association{relation: relation, other: other.Table, list: true, tags: tags})
+		}
+	}
+	return list
+}
+
+// matchAssociations assigns the associations to the columns that declare them ("Posts []Post"). Many-to-many
+// relations that are declared on such a column are always assigned to it.
+func matchAssociations(table *schema.Table, associations []*association) map[*schema.Column]*association {
+	matches := map[*schema.Column]*association{}
+	matched := map[*association]bool{}
+	for _, a := range associations {
+		if a.relation.Type == schema.ManyToMany {
+			for _, column := range []*schema.Column{a.relation.From, a.relation.To} {
+				if column.Table == table && column.IsVirtual() && column.List {
+					matches[column] = a
+					matched[a] = true
+				}
+			}
+		}
+	}
+	for _, a := range associations {
+		if matched[a] {
+			continue
+		}
+		for _, column := range table.Columns {
+			if _, ok := matches[column]; !ok && column.Ref == nil && column.Object == a.other && column.List == a.list {
+				matches[column] = a
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func isMatched(matches map[*schema.Column]*association, a *association) bool {
+	for _, match := range matches {
+		if match == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package dbmlgorm
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tag families that are derived from the schema (option OTags)
+const (
+	TagJSON     = "json"
+	TagValidate = "validate"
+)
+
+// noteTagRe matches a struct tag like validate:"min=3" at the start of the tags of a note
+var noteTagRe = regexp.MustCompile(`^([^\s:"\x60]+):("(?:[^"\\]|\\.)*")`)
+
+// noteTags returns the struct tags of the note of a column (tags:`validate:"min=3" xml:"name,attr"`) by key
+func noteTags(column *schema.Column) (map[string]string, error) {
+	tags := map[string]string{}
+	rest := strings.Join(column.Settings.Only(schema.PrefixTags), " ")
+	for rest != "" {
+		match := noteTagRe.FindStringSubmatch(rest)
+		if match == nil {
+			return tags, fmt.Errorf("invalid struct tag %v (expected key:\"value\")", strings.Fields(rest)[0])
+		}
+		value, err := strconv.Unquote(match[2])
+		if err != nil {
+			return tags, fmt.Errorf("invalid struct tag %v: %v", match[0], err)
+		}
+		tags[match[1]] = value
+		rest = strings.TrimSpace(rest[len(match[0]):])
+	}
+	return tags, nil
+}
+
+// tagFamilies returns the tag families of the option OTags
+func tagFamilies(ctx *common.Context) map[string]bool {
+	families := map[string]bool{}
+	for _, family := range strings.Split(ctx.Options.Get(OTags, ""), ",") {
+		if family = strings.TrimSpace(family); family != "" {
+			families[family] = true
+		}
+	}
+	return families
+}
+
+// checkTagsOption reports unknown tag families of the option OTags
+func checkTagsOption(ctx *common.Context) {
+	for family := range tagFamilies(ctx) {
+		if family != TagJSON && family != TagValidate {
+			ctx.Diagnostics.Errorf("", "", "unknown tag family %v in option %v (expected %v or %v)", family, OTags,
+				TagJSON, TagValidate)
+		}
+	}
+}
+
+// structTag returns the struct tag with the tags (e.g. `gorm:"not null"`) that are not empty or an empty string
+func structTag(tags ...string) string {
+	var nonEmpty []string
+	for _, tag := range tags {
+		if tag != "" {
+			nonEmpty = append(nonEmpty, tag)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
+	}
+	return " `" + strings.Join(nonEmpty, " ") + "`"
+}
+
+// fieldTags returns the tags of a field besides the gorm tag: the json tag (the field is named jsonName in JSON), the
+// validate tag (rules) and the tags of the note of the column the field belongs to (noteTags), which take precedence.
+// column can be nil for fields that are not declared by a column.
+func fieldTags(ctx *common.Context, jsonName string, omitEmpty bool, rules []string, column *schema.Column) string {
+	families := tagFamilies(ctx)
+	values := map[string]string{}
+	if families[TagJSON] {
+		values[TagJSON] = jsonName
+		if omitEmpty {
+			values[TagJSON] += ",omitempty"
+		}
+	}
+	if families[TagValidate] && len(rules) > 0 {
+		values[TagValidate] = strings.Join(rules, ",")
+	}
+	if column != nil {
+		tags, _ := noteTags(column) // invalid tags are reported once per column by dbmlTableToGormString
+		for key, value := range tags {
+			values[key] = value
+		}
+	}
+	var keys []string
+	for key := range values {
+		if key != TagJSON && key != TagValidate {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var tags []string
+	for _, key := range append([]string{TagJSON, TagValidate}, keys...) {
+		if value, ok := values[key]; ok {
+			tags = append(tags, fmt.Sprintf("%v:%q", key, value))
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// validateRules returns the validation rules of a column of the Go type goType: columns that are not null are
+// required unless the database sets them (primary keys, increments and defaults) or they are booleans, email columns
+// are validated as emails and the length of varchar columns is limited
+func validateRules(column *schema.Column, goType string) []string {
+	var rules []string
+	if column.Null && !column.PK {
+		rules = append(rules, "omitempty")
+	} else if !column.PK && !column.Increment && column.Default == "" && goType != "bool" {
+		rules = append(rules, "required")
+	}
+	dbType := strings.ToLower(column.DBType)
+	if dbType == "email" {
+		rules = append(rules, "email")
+	}
+	if (dbType == "varchar" || dbType == "char") && len(column.TypeParams) == 1 {
+		rules = append(rules, "max="+column.TypeParams[0])
+	}
+	if len(rules) == 1 && rules[0] == "omitempty" {
+		return nil
+	}
+	return rules
+}
@@ -0,0 +1,67 @@
+package dbmlgorm
+
+import (
+	"github.com/shifty11/dbml-convert/common"
+	"github.com/shifty11/dbml-convert/schema"
+	"strings"
+)
+
+// isSpecialSetting reports whether a gorm setting of a column note is handled by the generator instead of being
+// added to the gorm tag
+func isSpecialSetting(entry string) bool {
+	return entry == common.OCreatedAt || entry == common.OUpdatedAt || entry == common.SSoftDelete
+}
+
+// timestampSettings returns the gorm settings of the special options CreatedAt and UpdatedAt of a column
+func timestampSettings(column *schema.Column) []string {
+	var settings []string
+	if column.Settings.Has(schema.TargetGorm, common.OCreatedAt) {
+		settings = append(settings, "autoCreateTime")
+	}
+	if column.Settings.Has(schema.TargetGorm, common.OUpdatedAt) {
+		settings = append(settings, "autoUpdateTime")
+	}
+	return settings
+}
+
+// isSoftDelete reports whether a column of the Go type goType is the deletion time of soft deleted models, either by
+// the setting soft_delete or by the name deleted_at
+func isSoftDelete(column *schema.Column, goType string) bool {
+	if column.Settings.Has(schema.TargetGorm, common.SSoftDelete) {
+		return true
+	}
+	return column.SnakeName() == "deleted_at" && strings.TrimPrefix(goType, "*") == "time.Time"
+}
+
+// softDeleteField is the field of tables with the table setting soft_delete that have no soft delete column
+const softDeleteField = "DeletedAt gorm.DeletedAt `gorm:\"index\"`"
+
+// modelColumns returns the columns of a table that are replaced by embedding gorm.Model (option OModel) or nil if the
+// table does not have all of them: a primary key id of an unsigned or signed integer type and the times created_at,
+// updated_at and deleted_at
+func modelColumns(table *schema.Table, ctx *common.Context) map[*schema.Column]bool {
+	if ctx.Options.Get(OModel, "false") != "true" {
+		return nil
+	}
+	columns := map[*schema.Column]bool{}
+	for _, column := range table.Columns {
+		goType := ctx.ColumnType(types, column)
+		if columnName(column) != column.SnakeName() {
+			continue // gorm.Model uses other column names
+		}
+		switch column.SnakeName() {
+		case "id":
+			if column.PK && (goType == "uint" || goType == "int") {
+				columns[column] = true
+			}
+		case "created_at", "updated_at", "deleted_at":
+			if goType == "time.Time" && column.Ref == nil {
+				columns[column] = true
+			}
+		}
+	}
+	if len(columns) != 4 {
+		return nil
+	}
+	return columns
+}
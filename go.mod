@@ -6,6 +6,7 @@ require (
 	github.com/duythinht/dbml-go v0.0.0-20200817093442-0ac9a1ebc4a5
 	github.com/gobeam/stringy v0.0.0-20200717095810-8a3637503f62
 	github.com/stretchr/stew v0.0.0-20130812190256-80ef0842b48b
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace github.com/duythinht/dbml-go => github.com/shifty11/dbml-go v0.1.3
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"github.com/shifty11/dbml-convert/common"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// stdinPath is the input path that reads the dbml from stdin
+const stdinPath = "-"
+
+// expandInputs expands the glob patterns of the input paths. Files that are matched more than once are only
+// returned once.
+func expandInputs(patterns []string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if pattern != stdinPath {
+			var err error
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("invalid input pattern %v: %w", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no input file matches %v", pattern)
+			}
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+func parseDbml(path string) (common.Document, error) {
+	var src []byte
+	var err error
+	file := path
+	if path == stdinPath {
+		file = "<stdin>"
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return common.Document{}, err
+	}
+	return common.ParseDocument(file, src)
+}
+
+// parseInputs parses all input files and merges them. Parse errors and conflicts between the files are added to
+// diag, the returned document has no dbml if a file could not be parsed.
+func parseInputs(paths []string, diag *common.Diagnostics) (common.Document, error) {
+	var documents []common.Document
+	parseFailed := false
+	for _, path := range paths {
+		document, err := parseDbml(path)
+		if _, ok := err.(*common.Error); ok {
+			diag.Add(common.SeverityError, err)
+			parseFailed = true
+			continue
+		} else if err != nil {
+			return common.Document{}, err
+		}
+		documents = append(documents, document)
+	}
+	if parseFailed {
+		return common.Document{}, nil
+	}
+	return common.MergeDocuments(documents, diag), nil
+}
@@ -1,70 +1,127 @@
 package main
 
 import (
-	"flag"
 	"fmt"
-	"github.com/duythinht/dbml-go/core"
-	"github.com/duythinht/dbml-go/parser"
-	"github.com/duythinht/dbml-go/scanner"
-	"github.com/shifty11/dbml-convert/dbmldjango"
-	"github.com/shifty11/dbml-convert/dbmlent"
-	"github.com/shifty11/dbml-convert/dbmlgorm"
+	"github.com/shifty11/dbml-convert/common"
+	_ "github.com/shifty11/dbml-convert/dbmldjango"
+	_ "github.com/shifty11/dbml-convert/dbmlent"
+	_ "github.com/shifty11/dbml-convert/dbmlgorm"
+	"github.com/shifty11/dbml-convert/schema"
 	"os"
+	"strings"
 )
 
-func parseArgs() (string, string, bool, bool, bool) {
-	flag.Usage = func() { // Showing useful information when the user enters the --help option
-		flag.PrintDefaults()
-		fmt.Printf("-django|-gorm|-ent <path-to-dbml-file> <path-to-output>\n")
-	}
-	toDjango := flag.Bool("django", false, "Creates Django models")
-	toGorm := flag.Bool("gorm", false, "Creates Gorm models")
-	toEnt := flag.Bool("ent", false, "Creates Ent models")
-	flag.Parse()
-
-	if len(flag.Args()) < 2 || (!*toDjango && !*toGorm && !*toEnt) || (*toDjango && *toGorm && *toEnt) {
-		flag.Usage()
-		os.Exit(1)
+func listTargets() {
+	for _, generator := range common.Generators() {
+		fmt.Printf("%v\t%v\n", generator.Name(), generator.Description())
+		for _, option := range generator.Options() {
+			fmt.Printf("    %v\t%v (default: %v)\n", option.Name, option.Description, option.Default)
+		}
 	}
+}
 
-	dbmlPath := flag.Arg(0)
-	outputPath := flag.Arg(1)
+// exitWithError prints err as diagnostic and exits with a non-zero exit code
+func exitWithError(err error) {
+	printError(err)
+	os.Exit(1)
+}
 
-	if dbmlPath == "" || outputPath == "" {
-		flag.PrintDefaults()
-		os.Exit(1)
-	}
-	return dbmlPath, outputPath, *toDjango, *toGorm, *toEnt
+// printError prints err as diagnostic
+func printError(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
 }
 
-func parseDbml(dbmlPath string) *core.DBML {
-	file, err := os.Open(dbmlPath)
+// run creates the targets and writes, checks or prints their files. It returns the templates the generators looked
+// for and false if the run failed.
+func run(args args) ([]string, bool) {
+	diag := &common.Diagnostics{}
+	document, err := parseInputs(args.dbmlPaths, diag)
 	if err != nil {
-		panic(err)
+		printError(err)
+		return nil, false
+	}
+	if document.DBML == nil {
+		diag.Report(os.Stderr)
+		fmt.Fprintf(os.Stderr, "No files were written\n")
+		return nil, false
 	}
 
-	scan := scanner.NewScanner(file)
-	pars := parser.NewParser(scan)
-	dbml, err := pars.Parse()
-	if err != nil {
-		panic(err)
+	s := schema.Build(document.DBML, diag)
+	s.ApplyExtensions(document.Extensions)
+	var outputs []common.Output
+	var templates []string
+	for _, target := range args.targets {
+		diag.SetTarget(target.generator.Name())
+		ctx := &common.Context{
+			Schema:      s,
+			OutputPath:  target.outputPath,
+			Options:     args.targetOptions(target),
+			Types:       target.types,
+			Naming:      args.naming,
+			Diagnostics: diag,
+		}
+		files, err := target.generator.Generate(ctx)
+		templates = append(templates, ctx.Templates...)
+		if err != nil {
+			printError(fmt.Errorf("%v: %w", target.generator.Name(), err))
+			return templates, false
+		}
+		outputs = append(outputs, common.Output{Target: target.generator.Name(), Path: target.outputPath, Files: files})
+	}
+	diag.Locate(document.SourceMap)
+	diag.Report(os.Stderr)
+	if diag.Failed(args.werror) {
+		fmt.Fprintf(os.Stderr, "No files were written\n")
+		return templates, false
 	}
-	return dbml
+	if args.check {
+		diff, err := common.CheckOutputs(outputs)
+		if err != nil {
+			printError(err)
+			return templates, false
+		}
+		if diff != "" {
+			fmt.Print(diff)
+			fmt.Fprintf(os.Stderr, "Generated files are not up to date\n")
+			return templates, false
+		}
+		return templates, true
+	}
+	if args.stdout {
+		fmt.Print(common.PrintOutputs(outputs))
+		return templates, true
+	}
+	if args.dryRun {
+		changes, err := common.PlanOutputs(outputs)
+		if err != nil {
+			printError(err)
+			return templates, false
+		}
+		fmt.Print(common.DescribeChanges(changes))
+		return templates, true
+	}
+	if err := common.WriteOutputs(outputs); err != nil {
+		printError(err)
+		return templates, false
+	}
+	for _, output := range outputs {
+		fmt.Printf("Created %v models\nInput: %v\nOutput:%v\n", output.Target, strings.Join(args.dbmlPaths, ", "),
+			output.Path)
+	}
+	return templates, true
 }
 
 func main() {
-	dbmlPath, outputPath, toDjango, toGorm, toEnt := parseArgs()
-
-	dbml := parseDbml(dbmlPath)
+	if len(os.Args) > 1 && os.Args[1] == "list-targets" {
+		listTargets()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		watch(parseArgs(os.Args[2:]))
+		return
+	}
 
-	if toDjango {
-		dbmldjango.CreateDjangoFiles(dbml, outputPath)
-		fmt.Printf("Created Django models\nInput: %v\nOutput:%v\n", dbmlPath, outputPath)
-	} else if toGorm {
-		dbmlgorm.CreateGormFiles(dbml, outputPath)
-		fmt.Printf("Created Gorm models\nInput: %v\nOutput:%v\n", dbmlPath, outputPath)
-	} else if toEnt {
-		dbmlent.CreateEntFiles(dbml, outputPath)
-		fmt.Printf("Created Ent models\nInput: %v\nOutput:%v\n", dbmlPath, outputPath)
+	if _, ok := run(parseArgs(os.Args[1:])); !ok {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,208 @@
+package schema
+
+import (
+	"github.com/duythinht/dbml-go/core"
+	"regexp"
+	"strings"
+)
+
+var typeParamsRe = regexp.MustCompile(`^([^(]*)\((.*)\)$`)
+
+// Build creates the schema from the parsed dbml and validates it. Problems are reported to reporter, the returned
+// schema contains everything that could be resolved.
+func Build(dbml *core.DBML, reporter Reporter) *Schema {
+	s := &Schema{Project: dbml.Project}
+	for _, enum := range dbml.Enums {
+		if s.Enum(enum.Name) != nil {
+			reporter.Errorf("", "", "enum %v is defined more than once", enum.Name)
+			continue
+		}
+		e := &Enum{Name: enum.Name}
+		for _, value := range enum.Values {
+			e.Values = append(e.Values, EnumValue{Name: value.Name, Note: value.Note})
+		}
+		s.Enums = append(s.Enums, e)
+	}
+	var dbmlTables []core.Table // the dbml table of each table in s.Tables
+	for _, table := range dbml.Tables {
+		if s.Table(table.Name) != nil {
+			reporter.Errorf(table.Name, "", "table %v is defined more than once", table.Name)
+			continue
+		}
+		s.Tables = append(s.Tables, &Table{
+			Name:     table.Name,
+			Alias:    table.As,
+			Note:     table.Note,
+			Settings: parseSettings(table.Note),
+		})
+		dbmlTables = append(dbmlTables, table)
+	}
+	for i, table := range s.Tables {
+		s.buildColumns(table, dbmlTables[i], reporter)
+	}
+	for i, table := range s.Tables {
+		s.buildIndexes(table, dbmlTables[i], reporter)
+	}
+	for i, table := range s.Tables {
+		for j, column := range dbmlTables[i].Columns {
+			if column.Settings.Ref.Type != core.None {
+				s.addRelation("", table.Columns[j], column.Settings.Ref.Type, column.Settings.Ref.To, true, reporter)
+			}
+		}
+	}
+	for _, ref := range dbml.Refs {
+		for _, relationship := range ref.Relationships {
+			from := s.resolveColumn(relationship.From, "", "", reporter)
+			if from != nil {
+				s.addRelation(ref.Name, from, relationship.Type, relationship.To, false, reporter)
+			}
+		}
+	}
+	return s
+}
+
+func (s *Schema) buildColumns(table *Table, dbmlTable core.Table, reporter Reporter) {
+	for _, column := range dbmlTable.Columns {
+		if table.Column(column.Name) != nil {
+			reporter.Errorf(table.Name, column.Name, "column %v is defined more than once", column.Name)
+		}
+		c := &Column{
+			Table:     table,
+			Name:      column.Name,
+			Type:      column.Type,
+			PK:        column.Settings.PK,
+			Unique:    column.Settings.Unique,
+			Null:      column.Settings.Null,
+			Increment: column.Settings.Increment,
+			Default:   column.Settings.Default,
+			Note:      column.Settings.Note,
+			Settings:  parseSettings(column.Settings.Note),
+		}
+		if match := typeParamsRe.FindStringSubmatch(c.Type); match != nil {
+			c.Type = match[1]
+			for _, param := range strings.Split(match[2], ",") {
+				c.TypeParams = append(c.TypeParams, strings.TrimSpace(param))
+			}
+		}
+		if strings.HasPrefix(c.Type, "[]") {
+			c.Type = c.Type[2:]
+			c.List = true
+		}
+		c.DBType = c.Type
+		if alias, ok := typeAliases[strings.ToLower(c.Type)]; ok {
+			c.Type = alias
+		}
+		c.Enum = s.Enum(c.Type)
+		if c.Enum == nil {
+			c.Object = s.Table(c.Type)
+		}
+		table.Columns = append(table.Columns, c)
+	}
+}
+
+func (s *Schema) buildIndexes(table *Table, dbmlTable core.Table, reporter Reporter) {
+	for _, index := range dbmlTable.Indexes {
+		i := &Index{
+			Table:    table,
+			Name:     index.Settings.Name,
+			Unique:   index.Settings.Unique,
+			PK:       index.Settings.PK,
+			Type:     index.Settings.Type,
+			Note:     index.Settings.Note,
+			Settings: parseSettings(index.Settings.Note),
+		}
+		for _, field := range index.Fields {
+			column := table.Column(field)
+			if column == nil {
+				reporter.Errorf(table.Name, "", "index column %v does not exist", field)
+				continue
+			}
+			i.Columns = append(i.Columns, column)
+		}
+		// indexes without fields are expression indexes, their expressions are added by ApplyExtensions
+		if len(i.Columns) > 0 || len(index.Fields) == 0 {
+			table.Indexes = append(table.Indexes, i)
+		}
+	}
+}
+
+// resolveColumn resolves a reference of the form "table.column" (or "schema.table.column")
+func (s *Schema) resolveColumn(ref string, table string, column string, reporter Reporter) *Column {
+	split := strings.Split(ref, ".")
+	if len(split) < 2 {
+		reporter.Errorf(table, column, "reference %v is not of the form table.column", ref)
+		return nil
+	}
+	refTable := s.Table(split[len(split)-2])
+	if refTable == nil {
+		reporter.Errorf(table, column, "referenced table %v does not exist", split[len(split)-2])
+		return nil
+	}
+	refColumn := refTable.Column(split[len(split)-1])
+	if refColumn == nil {
+		reporter.Errorf(table, column, "referenced column %v does not exist in table %v", split[len(split)-1],
+			refTable.Name)
+		return nil
+	}
+	return refColumn
+}
+
+func (s *Schema) addRelation(name string, from *Column, relationType core.RelationshipType, to string, inline bool,
+	reporter Reporter) {
+	toColumn := s.resolveColumn(to, from.Table.Name, from.Name, reporter)
+	if toColumn == nil {
+		return
+	}
+	relation := &Relation{Name: name, Type: relationType, From: from, To: toColumn, Inline: inline}
+	if relationType == core.OneToMany {
+		relation.Type = core.ManyToOne
+		relation.From, relation.To = toColumn, from
+	}
+	if relationType == ManyToMany {
+		s.Relations = append(s.Relations, relation)
+		return
+	}
+	if relation.From.Ref != nil {
+		reporter.Errorf(relation.From.Table.Name, relation.From.Name, "column references more than one column")
+		return
+	}
+	relation.From.Ref = relation
+	s.Relations = append(s.Relations, relation)
+}
+
+// ApplyExtensions adds the extensions to the schema. Extensions of references or indexes that could not be resolved
+// are ignored, the problem was already reported by Build.
+func (s *Schema) ApplyExtensions(extensions Extensions) {
+	ignore := ignoreReporter{}
+	for _, action := range extensions.RefActions {
+		from := s.resolveColumn(action.From, "", "", ignore)
+		to := s.resolveColumn(action.To, "", "", ignore)
+		if from == nil || to == nil {
+			continue
+		}
+		for _, relation := range s.Relations {
+			if (relation.From == from && relation.To == to) || (relation.From == to && relation.To == from) {
+				relation.OnDelete = action.OnDelete
+				relation.OnUpdate = action.OnUpdate
+			}
+		}
+	}
+	for _, expression := range extensions.IndexExpressions {
+		if table := s.Table(expression.Table); table != nil && expression.Index < len(table.Indexes) {
+			table.Indexes[expression.Index].Expressions = expression.Expressions
+		}
+	}
+	for _, columnType := range extensions.ColumnTypes {
+		if table := s.Table(columnType.Table); table != nil {
+			if column := table.Column(columnType.Column); column != nil {
+				column.TypeParams = columnType.TypeParams
+			}
+		}
+	}
+}
+
+// ignoreReporter drops all problems
+type ignoreReporter struct{}
+
+func (ignoreReporter) Warnf(string, string, string, ...interface{})  {}
+func (ignoreReporter) Errorf(string, string, string, ...interface{}) {}
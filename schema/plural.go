@@ -0,0 +1,55 @@
+package schema
+
+import "strings"
+
+// pluralRules are the endings of singular words and their plural endings, the first matching rule applies. They are
+// the inflection rules of Ent and Gorm (which both follow Rails), so that Plural agrees with their table names.
+var pluralRules = [][2]string{
+	// irregular words
+	{"person", "people"}, {"man", "men"}, {"child", "children"}, {"sex", "sexes"}, {"move", "moves"},
+	{"zombie", "zombies"},
+	{"matrix", "matrices"}, {"vertex", "vertices"}, {"index", "indices"},
+	{"sh", "shes"}, {"ss", "sses"}, {"ch", "ches"}, {"x", "xes"},
+	{"quy", "quies"}, {"hive", "hives"},
+	{"sis", "ses"}, {"ium", "ia"}, {"tum", "ta"}, {"ia", "ia"}, {"ta", "ta"},
+	{"tomato", "tomatoes"}, {"buffalo", "buffaloes"},
+	{"bus", "buses"}, {"status", "statuses"}, {"alias", "aliases"},
+	{"virus", "viri"}, {"octopus", "octopi"}, {"viri", "viri"}, {"octopi", "octopi"},
+	// words that are plural already
+	{"s", "s"},
+}
+
+// pluralWords are names that only have these plurals as a whole
+var pluralWords = map[string]string{"ox": "oxen", "oxen": "oxen", "quiz": "quizzes", "mouse": "mice", "mice": "mice",
+	"louse": "lice", "lice": "lice", "axis": "axes", "testis": "testes"}
+
+// uncountable are names that have no plural
+var uncountable = map[string]bool{"equipment": true, "information": true, "rice": true, "money": true,
+	"species": true, "series": true, "fish": true, "sheep": true, "jeans": true, "police": true}
+
+// Plural returns the English plural of a name, e.g. "categories" for "category" or "order_items" for "order_item".
+// Names that are plural already stay as they are ("users").
+func Plural(name string) string {
+	word := strings.ToLower(name)
+	if word == "" || uncountable[word] {
+		return name
+	}
+	if plural, ok := pluralWords[word]; ok {
+		return name[:1] + plural[1:]
+	}
+	for _, rule := range pluralRules {
+		if strings.HasSuffix(word, rule[0]) {
+			return name[:len(name)-len(rule[0])] + rule[1]
+		}
+	}
+	if n := len(word); n >= 2 && word[n-1] == 'y' && !strings.ContainsRune("aeiouy", rune(word[n-2])) {
+		return name[:len(name)-1] + "ies"
+	}
+	if n := len(word); n >= 3 && strings.HasSuffix(word, "fe") && word[n-3] != 'f' {
+		return name[:len(name)-2] + "ves"
+	}
+	if strings.HasSuffix(word, "lf") || strings.HasSuffix(word, "rf") {
+		return name[:len(name)-1] + "ves"
+	}
+	return name + "s"
+}
@@ -0,0 +1,51 @@
+package schema
+
+import "testing"
+
+// TestPlural compares Plural with the plurals of the inflection of Gorm (github.com/jinzhu/inflection)
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"user", "users"},
+		{"users", "users"},
+		{"User", "Users"},
+		{"category", "categories"},
+		{"UserCategory", "UserCategories"},
+		{"order_item", "order_items"},
+		{"address", "addresses"},
+		{"status", "statuses"},
+		{"box", "boxes"},
+		{"church", "churches"},
+		{"key", "keys"},
+		{"soliloquy", "soliloquies"},
+		{"wife", "wives"},
+		{"wolf", "wolves"},
+		{"giraffe", "giraffes"},
+		{"analysis", "analyses"},
+		{"medium", "media"},
+		{"person", "people"},
+		{"child", "children"},
+		{"woman", "women"},
+		{"mouse", "mice"},
+		{"Mouse", "Mice"},
+		{"blouse", "blouses"},
+		{"ox", "oxen"},
+		{"quiz", "quizzes"},
+		{"index", "indices"},
+		{"equipment", "equipment"},
+		{"information", "information"},
+		{"user_information", "user_informations"},
+		{"tomato", "tomatoes"},
+		{"photo", "photos"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Plural(test.name); got != test.want {
+				t.Errorf("Plural(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package schema contains the normalized representation of a dbml file that is shared by all generators.
+//
+// The representation is built once from core.DBML: names are resolved, enums and relations are linked to their
+// columns and the generator settings of the notes are parsed. Generators must not interpret core.DBML themselves.
+package schema
+
+import (
+	"github.com/duythinht/dbml-go/core"
+	"github.com/gobeam/stringy"
+	"strings"
+)
+
+// Reporter receives the problems found while building the schema (implemented by common.Diagnostics).
+type Reporter interface {
+	Warnf(table string, column string, format string, args ...interface{})
+	Errorf(table string, column string, format string, args ...interface{})
+}
+
+// Schema is the normalized dbml schema.
+type Schema struct {
+	Project   core.Project
+	Tables    []*Table
+	Enums     []*Enum
+	Relations []*Relation
+}
+
+// Table is a dbml table.
+type Table struct {
+	Name     string
+	Alias    string
+	Note     string
+	Settings Settings
+	Columns  []*Column
+	Indexes  []*Index
+}
+
+// Column is a column of a table.
+type Column struct {
+	Table *Table
+	Name  string
+	// Type is the normalized dbml type without parameters and list prefix, e.g. "string" for "varchar(255)".
+	Type string
+	// DBType is the type as written in dbml without parameters and list prefix, e.g. "varchar" for "varchar(255)".
+	DBType string
+	// TypeParams are the parameters of the type, e.g. ["255"] for "varchar(255)".
+	TypeParams []string
+	// List is true for types of the form "[]Table".
+	List bool
+	// Enum is set if the type is an enum.
+	Enum *Enum
+	// Object is set if the type is the name of a table, e.g. "User" or "[]User".
+	Object    *Table
+	PK        bool
+	Unique    bool
+	Null      bool
+	Increment bool
+	Default   string
+	Note      string
+	Settings  Settings
+	// Ref is set if the column holds the foreign key of a relation.
+	Ref *Relation
+}
+
+// Enum is a dbml enum.
+type Enum struct {
+	Name   string
+	Values []EnumValue
+}
+
+// EnumValue is a value of an enum.
+type EnumValue struct {
+	Name string
+	Note string
+}
+
+// ManyToMany is the type of a many-to-many reference (<>). The dbml parser does not support it, such references are
+// extracted from the source before it is parsed (see common.ParseDocument).
+const ManyToMany core.RelationshipType = 100
+
+// Relation is a reference between two columns. It is normalized so that From always holds the foreign key, a
+// one-to-many reference (<) is therefore stored as many-to-one (>) with swapped ends. The columns of a many-to-many
+// relation do not hold a foreign key, they are referenced by the join table.
+type Relation struct {
+	Name string
+	// Type is core.ManyToOne, core.OneToOne or ManyToMany.
+	Type core.RelationshipType
+	From *Column
+	To   *Column
+	// Inline is true if the relation was declared in the column settings instead of a Ref block.
+	Inline bool
+	// OnDelete and OnUpdate are the referential actions in lower case, e.g. "cascade" or "set null".
+	OnDelete string
+	OnUpdate string
+}
+
+// Extensions are the parts of a dbml source that the dbml parser does not support. They are extracted from the source
+// before it is parsed (see common.ParseDocument) and added to the schema by ApplyExtensions.
+type Extensions struct {
+	RefActions       []RefAction
+	IndexExpressions []IndexExpression
+	ColumnTypes      []ColumnType
+}
+
+// RefAction holds the referential actions of the reference between two columns ("table.column").
+type RefAction struct {
+	From     string
+	To       string
+	OnDelete string
+	OnUpdate string
+}
+
+// IndexExpression holds the expressions of the index at position Index in the indexes of a table.
+type IndexExpression struct {
+	Table       string
+	Index       int
+	Expressions []string
+}
+
+// ColumnType holds the parameters of the type of a column if there is more than one, e.g. ["10", "2"] for
+// decimal(10,2).
+type ColumnType struct {
+	Table      string
+	Column     string
+	TypeParams []string
+}
+
+// Index is an index of a table.
+type Index struct {
+	Table   *Table
+	Name    string
+	Columns []*Column
+	// Expressions are the expressions of an expression index, e.g. "lower(email)".
+	Expressions []string
+	Unique      bool
+	PK          bool
+	// Type is "btree", "hash" or empty.
+	Type     string
+	Note     string
+	Settings Settings
+}
+
+// typeAliases maps SQL types to the types understood by the generators
+var typeAliases = map[string]string{
+	"varchar":     "string",
+	"char":        "string",
+	"text":        "string",
+	"integer":     "int",
+	"smallint":    "int",
+	"bigint":      "int",
+	"serial":      "int",
+	"timestamp":   "datetime",
+	"timestamptz": "datetime",
+	"numeric":     "decimal",
+}
+
+// SnakeCase converts a dbml name into snake case, e.g. "CreatedAt" -> "created_at".
+func SnakeCase(name string) string {
+	return strings.ToLower(stringy.New(name).SnakeCase("?", "").Get())
+}
+
+// Table returns the table with the given name or alias.
+func (s *Schema) Table(name string) *Table {
+	for _, table := range s.Tables {
+		if table.Name == name || (table.Alias != "" && table.Alias == name) {
+			return table
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum with the given name. Enum names are case insensitive.
+func (s *Schema) Enum(name string) *Enum {
+	for _, enum := range s.Enums {
+		if strings.ToLower(enum.Name) == strings.ToLower(name) {
+			return enum
+		}
+	}
+	return nil
+}
+
+// RelationsTo returns the relations that reference the table. Many-to-many relations are returned for both tables.
+func (s *Schema) RelationsTo(table *Table) []*Relation {
+	var relations []*Relation
+	for _, relation := range s.Relations {
+		if relation.To.Table == table || (relation.Type == ManyToMany && relation.From.Table == table) {
+			relations = append(relations, relation)
+		}
+	}
+	return relations
+}
+
+// SnakeName returns the name of the table in snake case.
+func (t *Table) SnakeName() string {
+	return SnakeCase(t.Name)
+}
+
+// Column returns the column with the given name.
+func (t *Table) Column(name string) *Column {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// SnakeName returns the name of the column in snake case.
+func (c *Column) SnakeName() string {
+	return SnakeCase(c.Name)
+}
+
+// IsVirtual reports whether the column is no database column but declares an association of the form "Author User"
+// or "Posts []Post".
+func (c *Column) IsVirtual() bool {
+	return c.Object != nil
+}
+
+// RawType returns the type as written in dbml, e.g. "varchar(255)".
+func (c *Column) RawType() string {
+	str := c.DBType
+	if c.List {
+		str = "[]" + str
+	}
+	if len(c.TypeParams) > 0 {
+		str += "(" + strings.Join(c.TypeParams, ",") + ")"
+	}
+	return str
+}
+
+// Describe returns a description of the index for diagnostics, its name or its columns and expressions.
+func (i *Index) Describe() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	var fields []string
+	for _, column := range i.Columns {
+		fields = append(fields, column.Name)
+	}
+	for _, expression := range i.Expressions {
+		fields = append(fields, "`"+expression+"`")
+	}
+	return "(" + strings.Join(fields, ", ") + ")"
+}
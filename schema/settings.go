@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Targets used as prefix of the settings in dbml notes
+const (
+	TargetAll    = "all"
+	TargetDjango = "django"
+	TargetEnt    = "ent"
+	TargetGorm   = "gorm"
+)
+
+// PrefixTags is the prefix of the struct tags in the note of a column that Go generators add to the field as they
+// are, e.g. tags:`validate:"min=3" xml:"name,attr"`
+const PrefixTags = "tags"
+
+// Settings are the generator settings of a note, indexed by target.
+//
+// A note can hold settings for several targets. Settings in backticks are separated by spaces, settings in double
+// quotes by semicolons:
+//
+//	all:`CreatedAt` django:`model_path=app/models.py` gorm:"type:text;not null"
+//
+// Only the targets and PrefixTags start settings, other text of the note like see:"RFC 1234" is left alone.
+type Settings map[string][]string
+
+var settingsRe = regexp.MustCompile(`\b(` + strings.Join([]string{TargetAll, TargetDjango, TargetEnt, TargetGorm,
+	PrefixTags}, "|") + `):(?:\x60([^\x60]*)\x60|"([^"]*)")`)
+
+func parseSettings(note string) Settings {
+	settings := Settings{}
+	for _, match := range settingsRe.FindAllStringSubmatch(note, -1) {
+		target := match[1]
+		if match[2] != "" {
+			settings[target] = append(settings[target], strings.Fields(match[2])...)
+		} else {
+			for _, entry := range strings.Split(match[3], ";") {
+				if entry != "" {
+					settings[target] = append(settings[target], entry)
+				}
+			}
+		}
+	}
+	return settings
+}
+
+// NoteText returns the text of a note without its settings, e.g. "Email address" for
+// "Email address gorm:`uniqueIndex`".
+func NoteText(note string) string {
+	return strings.Join(strings.Fields(settingsRe.ReplaceAllString(note, "")), " ")
+}
+
+// Get returns the settings for the target including the settings for all targets.
+func (s Settings) Get(target string) []string {
+	var settings []string
+	settings = append(settings, s[TargetAll]...)
+	return append(settings, s[target]...)
+}
+
+// Only returns the settings for the target without the settings for all targets.
+func (s Settings) Only(target string) []string {
+	return s[target]
+}
+
+// Has reports whether the setting is set for the target or for all targets.
+func (s Settings) Has(target string, setting string) bool {
+	for _, entry := range s.Get(target) {
+		if entry == setting {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the value of a key=value setting for the target or for all targets.
+func (s Settings) Value(target string, key string) (string, bool) {
+	for _, entry := range s.Get(target) {
+		if strings.HasPrefix(entry, key+"=") {
+			return strings.TrimPrefix(entry, key+"="), true
+		}
+	}
+	return "", false
+}
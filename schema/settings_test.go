@@ -0,0 +1,31 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	tests := []struct {
+		note string
+		want Settings
+		text string
+	}{
+		{"Email address gorm:`uniqueIndex`", Settings{"gorm": {"uniqueIndex"}}, "Email address"},
+		{`all:"type:text;not null"`, Settings{"all": {"type:text", "not null"}}, ""},
+		{"Name tags:`validate:\"min=3\" xml:\"name,attr\"`",
+			Settings{"tags": {`validate:"min=3"`, `xml:"name,attr"`}}, "Name"},
+		{`Shown name, see:"RFC 1234"`, Settings{}, `Shown name, see:"RFC 1234"`},
+		{"install:`x` ent:`-`", Settings{"ent": {"-"}}, "install:`x`"},
+	}
+	for _, test := range tests {
+		t.Run(test.note, func(t *testing.T) {
+			if got := parseSettings(test.note); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSettings(%q) = %v, want %v", test.note, got, test.want)
+			}
+			if got := NoteText(test.note); got != test.text {
+				t.Errorf("NoteText(%q) = %q, want %q", test.note, got, test.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// pollInterval is the interval in which the watched files are checked for changes
+const pollInterval = 500 * time.Millisecond
+
+// debounceDelay is the time the watched files must be unchanged before the targets are regenerated, so that an
+// editor that saves several files (or writes a file in several steps) triggers only one run
+const debounceDelay = 300 * time.Millisecond
+
+// fileState is the state of a watched file, files that do not exist have the zero state
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the state of all files
+func snapshot(paths []string) map[string]fileState {
+	states := map[string]fileState{}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			states[path] = fileState{}
+		}
+	}
+	return states
+}
+
+func sameSnapshot(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other != state {
+			return false
+		}
+	}
+	return true
+}
+
+// watchedFiles returns the input files, templates and the configuration file. The input patterns are expanded again
+// so that new files that match a glob are picked up, if a pattern matches no file anymore the previous files are kept.
+func watchedFiles(args *args, templates []string) []string {
+	if dbmlPaths, err := expandInputs(args.inputs); err == nil {
+		args.dbmlPaths = dbmlPaths
+	}
+	paths := append(append([]string(nil), args.dbmlPaths...), templates...)
+	if args.commandLine.configPath != "" {
+		paths = append(paths, args.commandLine.configPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// regenerate loads the configuration file again and runs the targets. If the configuration file is invalid the error
+// is reported and the previous args are kept. The templates of a failed run are added to the previous ones, so that a
+// run that fails before the generators looked for their templates does not stop watching them.
+func regenerate(args *args, templates []string) []string {
+	reloaded, err := args.commandLine.load()
+	if err != nil {
+		printError(err)
+		return templates
+	}
+	*args = reloaded
+	found, ok := run(*args)
+	if ok {
+		return found
+	}
+	seen := map[string]bool{}
+	var all []string
+	for _, path := range append(append([]string(nil), templates...), found...) {
+		if !seen[path] {
+			seen[path] = true
+			all = append(all, path)
+		}
+	}
+	return all
+}
+
+// watch regenerates the targets whenever one of the input files, templates or the configuration file changes. Errors
+// are reported but do not stop watching.
+func watch(args args) {
+	for _, input := range args.inputs {
+		if input == stdinPath {
+			exitWithError(fmt.Errorf("stdin can not be watched"))
+		}
+	}
+	templates, _ := run(args)
+	paths := watchedFiles(&args, templates)
+	states := snapshot(paths)
+	fmt.Fprintf(os.Stderr, "Watching %v files for changes...\n", len(paths))
+	for {
+		time.Sleep(pollInterval)
+		current := snapshot(watchedFiles(&args, templates))
+		if sameSnapshot(states, current) {
+			continue
+		}
+		for {
+			time.Sleep(debounceDelay)
+			next := snapshot(watchedFiles(&args, templates))
+			if sameSnapshot(current, next) {
+				break
+			}
+			current = next
+		}
+		fmt.Fprintf(os.Stderr, "\n[%v] Change detected, regenerating...\n", time.Now().Format("15:04:05"))
+		templates = regenerate(&args, templates)
+		paths = watchedFiles(&args, templates)
+		states = snapshot(paths)
+	}
+}